@@ -10,6 +10,7 @@ import (
 
 func newApplyCommand() *cobra.Command {
 	var dryRun bool
+	var descriptorSetOut string
 
 	cmd := &cobra.Command{
 		Use:   "apply",
@@ -40,11 +41,18 @@ func newApplyCommand() *cobra.Command {
 				return fmt.Errorf("applying changes: %w", err)
 			}
 
+			if descriptorSetOut != "" {
+				if err := eng.WriteDescriptorSet(ctx, plan, descriptorSetOut); err != nil {
+					return fmt.Errorf("writing descriptor set: %w", err)
+				}
+			}
+
 			fmt.Println("Changes applied successfully")
 			return nil
 		},
 	}
 	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without making changes")
+	cmd.Flags().StringVar(&descriptorSetOut, "descriptor-set-out", "", "Also write the migrated schema as a FileDescriptorSet (.json for JSON)")
 
 	return cmd
 }
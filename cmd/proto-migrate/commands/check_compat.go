@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jackchuka/proto-migrate/internal/compat"
+	"github.com/jackchuka/proto-migrate/internal/config"
+	"github.com/jackchuka/proto-migrate/internal/engine"
+	"github.com/spf13/cobra"
+)
+
+func newCheckCompatCommand() *cobra.Command {
+	var jsonOpt bool
+
+	cmd := &cobra.Command{
+		Use:   "check-compat",
+		Short: "Reports wire and JSON incompatibilities between source and migrated schema",
+		Long: `Compares the original and migrated schemas message-by-message. Wire-breaking
+changes fail the command; changes to protojson names, enum value names and Any
+type URLs are reported separately since they only matter for JSON or Any payloads.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			flags := GetGlobalFlags()
+
+			cfg, err := config.Load(flags.Config)
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+
+			eng := engine.New(cfg, flags)
+			plan, err := eng.Plan(ctx)
+			if err != nil {
+				if jsonOpt {
+					if err := printLoadErrorJSON(err); err != nil {
+						return err
+					}
+				}
+				return fmt.Errorf("planning: %w", err)
+			}
+
+			if err := plan.CheckCompat(); err != nil {
+				return fmt.Errorf("checking compatibility: %w", err)
+			}
+
+			if jsonOpt {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(plan.Compat); err != nil {
+					return err
+				}
+			} else if len(plan.Compat) == 0 {
+				fmt.Println("No compatibility issues found")
+			} else {
+				for _, issue := range plan.Compat {
+					fmt.Println(issue.String())
+				}
+			}
+
+			return compatError(plan.Compat)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOpt, "json", false, "Print issues as JSON")
+
+	return cmd
+}
+
+// compatError returns an error when issues contain wire-breaking changes.
+func compatError(issues []compat.Issue) error {
+	if compat.HasSeverity(issues, compat.SeverityWire) {
+		return fmt.Errorf("migration introduces wire-breaking changes")
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 
 func newDiffCommand() *cobra.Command {
 	var exitCode bool
+	var againstTarget bool
 
 	cmd := &cobra.Command{
 		Use:   "diff",
@@ -31,7 +32,11 @@ func newDiffCommand() *cobra.Command {
 				return fmt.Errorf("planning: %w", err)
 			}
 
-			hasDiffs, err := plan.Diff(os.Stdout)
+			diff := plan.Diff
+			if againstTarget {
+				diff = plan.DiffTarget
+			}
+			hasDiffs, err := diff(os.Stdout)
 			if err != nil {
 				return fmt.Errorf("generating diff: %w", err)
 			}
@@ -45,5 +50,6 @@ func newDiffCommand() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&exitCode, "exit-code", false, "Exit with code 1 if there are differences")
+	cmd.Flags().BoolVar(&againstTarget, "target", false, "Diff the existing target directory against the migrated output")
 	return cmd
 }
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jackchuka/proto-migrate/internal/config"
+	"github.com/jackchuka/proto-migrate/internal/engine"
+	"github.com/jackchuka/proto-migrate/internal/resolve"
+	"github.com/spf13/cobra"
+)
+
+func newGraphCommand() *cobra.Command {
+	var format string
+	var after bool
+	var changes bool
+	var external bool
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Renders the import graph as DOT, Mermaid or JSON",
+		Long: `Renders the import graph of the source files, or with --after of the migrated
+files at their target paths. Sibling and external imports are drawn dashed;
+with --changes the files the plan changes are highlighted. The source graph is
+drawn without planning, so it renders even when the migration would fail;
+--after and --changes plan with import cycles allowed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			flags := GetGlobalFlags()
+
+			cfg, err := config.Load(flags.Config)
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+
+			if !after && !changes {
+				graph, _, err := engine.New(cfg, flags).Graph(ctx)
+				if err != nil {
+					return err
+				}
+				opts := resolve.ExportOptions{BaseDir: cfg.Source, External: external}
+				return graph.Export(opts).Render(os.Stdout, format)
+			}
+
+			// The graph is only drawn, so cycles and rule warnings must not
+			// stop it from being planned.
+			planFlags := *flags
+			planFlags.AllowCycles = true
+			planFlags.Strict = false
+			eng := engine.New(cfg, &planFlags)
+			plan, err := eng.Plan(ctx)
+			if err != nil {
+				return fmt.Errorf("planning: %w", err)
+			}
+
+			graph := plan.Graph
+			opts := resolve.ExportOptions{BaseDir: plan.SourceDir, External: external}
+			if changes {
+				opts.Changed = plan.ChangedFiles()
+			}
+			if after {
+				if graph, err = eng.TargetGraph(plan); err != nil {
+					return fmt.Errorf("building migrated graph: %w", err)
+				}
+				opts.BaseDir = plan.TargetDir
+				if changes {
+					opts.Changed, err = targetPaths(plan, opts.Changed)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return graph.Export(opts).Render(os.Stdout, format)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "dot", "Output format: dot, mermaid or json")
+	cmd.Flags().BoolVar(&after, "after", false, "Render the migrated graph instead of the source graph")
+	cmd.Flags().BoolVar(&changes, "changes", false, "Highlight the files the plan changes")
+	cmd.Flags().BoolVar(&external, "external", true, "Include sibling and external imports")
+
+	return cmd
+}
+
+// targetPaths maps a set of source and consumer paths to the paths they are
+// written to.
+func targetPaths(plan *engine.Plan, paths map[string]bool) (map[string]bool, error) {
+	targets := make(map[string]bool, len(paths))
+	for _, file := range append(plan.Files[:len(plan.Files):len(plan.Files)], plan.Consumers...) {
+		if !paths[file.Path] {
+			continue
+		}
+		targetPath, err := plan.TargetPath(file)
+		if err != nil {
+			return nil, err
+		}
+		targets[targetPath] = true
+	}
+	return targets, nil
+}
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jackchuka/proto-migrate/internal/config"
+	"github.com/jackchuka/proto-migrate/internal/engine"
+	"github.com/jackchuka/proto-migrate/internal/resolve"
+	"github.com/spf13/cobra"
+)
+
+func newImpactCommand() *cobra.Command {
+	var jsonOpt bool
+	var source string
+
+	cmd := &cobra.Command{
+		Use:   "impact <symbol-or-package>",
+		Short: "Lists what renaming a symbol or package would touch",
+		Long: `Lists every file, definition, reference and language option that renaming the
+given fully-qualified symbol or package would touch, with counts per directory.
+With --source no config file is needed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			flags := GetGlobalFlags()
+
+			var cfg *config.Config
+			if source != "" {
+				cfg = &config.Config{Source: source}
+			} else {
+				var err error
+				if cfg, err = config.Load(flags.Config); err != nil {
+					return fmt.Errorf("loading config: %w", err)
+				}
+			}
+
+			graph, _, err := engine.New(cfg, flags).Graph(ctx)
+			if err != nil {
+				if jsonOpt {
+					if err := printLoadErrorJSON(err); err != nil {
+						return err
+					}
+				}
+				return err
+			}
+
+			impact, err := graph.Impact(args[0])
+			if err != nil {
+				return err
+			}
+
+			if jsonOpt {
+				output := struct {
+					*resolve.Impact
+					Dirs []resolve.DirCount `json:"dirs"`
+				}{impact, impact.Dirs(cfg.Source)}
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(output)
+			}
+			printImpact(impact, cfg.Source)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOpt, "json", false, "Print the impact as JSON")
+	cmd.Flags().StringVar(&source, "source", "", "Directory to analyze instead of the config's source")
+
+	return cmd
+}
+
+func printImpact(impact *resolve.Impact, baseDir string) {
+	rel := func(path string) string {
+		if r, err := filepath.Rel(baseDir, path); err == nil {
+			return r
+		}
+		return path
+	}
+
+	fmt.Printf("\nImpact of renaming %s %s:\n", impact.Kind, impact.Name)
+	fmt.Printf("  Files: %d\n", len(impact.Files))
+	fmt.Printf("  Definitions: %d\n", len(impact.Definitions))
+	fmt.Printf("  References: %d\n", len(impact.References))
+	if impact.Kind == resolve.KindPackage {
+		fmt.Printf("  Importers: %d\n", len(impact.Importers))
+		fmt.Printf("  Language options: %d\n", len(impact.Options))
+	}
+
+	fmt.Println("\nFiles per directory:")
+	for _, dir := range impact.Dirs(baseDir) {
+		fmt.Printf("  %4d  %s\n", dir.Files, dir.Dir)
+	}
+
+	fmt.Println("\nDefinitions:")
+	for _, def := range impact.Definitions {
+		fmt.Printf("  • %s %s (%s:%d:%d)\n", def.Kind, def.Name, rel(def.File), def.Line, def.Column)
+	}
+
+	if len(impact.References) > 0 {
+		fmt.Println("\nReferences:")
+		for _, ref := range impact.References {
+			fmt.Printf("  • %s:%d:%d %s in %s -> %s\n", rel(ref.File), ref.Line, ref.Column, ref.Usage, ref.Context, ref.Symbol)
+		}
+	}
+
+	if len(impact.Importers) > 0 {
+		fmt.Println("\nImporters:")
+		for _, file := range impact.Importers {
+			fmt.Printf("  • %s\n", rel(file))
+		}
+	}
+
+	if len(impact.Options) > 0 {
+		fmt.Println("\nLanguage options:")
+		for _, option := range impact.Options {
+			fmt.Printf("  • %s:%d:%d %s = %q\n", rel(option.File), option.Line, option.Column, option.Name, option.Value)
+		}
+	}
+}
@@ -1,15 +1,20 @@
 package commands
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/jackchuka/proto-migrate/internal/config"
 	"github.com/jackchuka/proto-migrate/internal/engine"
+	"github.com/jackchuka/proto-migrate/internal/loader"
 	"github.com/spf13/cobra"
 )
 
 func newPlanCommand() *cobra.Command {
 	var jsonOpt bool
+	var checkCompat bool
 
 	cmd := &cobra.Command{
 		Use:   "plan",
@@ -27,16 +32,49 @@ func newPlanCommand() *cobra.Command {
 			eng := engine.New(cfg, flags)
 			plan, err := eng.Plan(ctx)
 			if err != nil {
+				if jsonOpt {
+					if err := printLoadErrorJSON(err); err != nil {
+						return err
+					}
+				}
 				return fmt.Errorf("planning: %w", err)
 			}
 
+			// Compatibility is always reported; --check-compat only makes
+			// wire-breaking changes fail the plan.
+			if err := plan.CheckCompat(); err != nil {
+				return fmt.Errorf("checking compatibility: %w", err)
+			}
+
 			if jsonOpt {
-				return plan.PrintJSON()
+				err = plan.PrintJSON()
+			} else {
+				err = plan.Print()
 			}
-			return plan.Print()
+			if err != nil {
+				return err
+			}
+
+			if !checkCompat {
+				return nil
+			}
+			return compatError(plan.Compat)
 		},
 	}
 	cmd.Flags().BoolVar(&jsonOpt, "json", false, "Plan format (default: plain text, use --json for JSON)")
+	cmd.Flags().BoolVar(&checkCompat, "check-compat", false, "Fail if the migration introduces wire-breaking changes")
 
 	return cmd
 }
+
+// printLoadErrorJSON writes the files that failed to load as JSON, so that
+// --json output stays machine-readable for a tree that does not parse.
+func printLoadErrorJSON(err error) error {
+	var loadErr *loader.Error
+	if !errors.As(err, &loadErr) {
+		return nil
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(loadErr)
+}
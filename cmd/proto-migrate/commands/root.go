@@ -18,14 +18,21 @@ is backward-compatible.`,
 	}
 
 	cmd.PersistentFlags().StringVar(&globalFlags.Config, "config", "", "Path to proto-migrate.yaml (default: auto-detect)")
+	cmd.PersistentFlags().StringArrayVarP(&globalFlags.ProtoPath, "proto-path", "I", nil, "Directory to search for imports; may be repeated (searched before include_paths)")
 	cmd.PersistentFlags().BoolVar(&globalFlags.VendorDeps, "vendor-deps", false, "Copy missing externals to vendor/")
 	cmd.PersistentFlags().IntVar(&globalFlags.Concurrency, "concurrency", 0, "Parallel file visits (default: #CPU)")
+	cmd.PersistentFlags().BoolVar(&globalFlags.Strict, "strict", false, "Treat plan warnings as errors")
+	cmd.PersistentFlags().BoolVar(&globalFlags.AllowCycles, "allow-cycles", false, "Allow import cycles in the migrated schema")
+	cmd.PersistentFlags().StringVar(&globalFlags.SourceRev, "source-rev", "", "Read the source tree from this git revision instead of the working tree")
 
 	cmd.AddCommand(
 		newInitCommand(),
 		newPlanCommand(),
 		newDiffCommand(),
 		newApplyCommand(),
+		newCheckCompatCommand(),
+		newGraphCommand(),
+		newImpactCommand(),
 		newVersionCommand(),
 	)
 
@@ -0,0 +1,256 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackchuka/proto-migrate/internal/loader"
+)
+
+// modTime is stamped on every written entry so that migrating the same tree
+// twice produces identical archives. Zip cannot represent earlier dates.
+var modTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// IsArchivePath reports whether p names a .zip, .tar.gz or .tgz archive.
+func IsArchivePath(p string) bool {
+	lower := strings.ToLower(p)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+func isZip(p string) bool {
+	return strings.HasSuffix(strings.ToLower(p), ".zip")
+}
+
+// NewSource presents the regular files of the archive at p below p, so that
+// an archive can be migrated like a directory. It is read on first use.
+func NewSource(p string) *loader.MemorySource {
+	return loader.NewMemorySource(p, func() (map[string][]byte, error) {
+		return Read(p)
+	})
+}
+
+// Read returns the regular files of the archive at p, keyed by their
+// slash-separated path inside the archive.
+func Read(p string) (map[string][]byte, error) {
+	var files map[string][]byte
+	var err error
+	if isZip(p) {
+		files, err = readZip(p)
+	} else {
+		files, err = readTarGz(p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading archive %s: %w", p, err)
+	}
+	return files, nil
+}
+
+func readZip(p string) (map[string][]byte, error) {
+	reader, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	files := make(map[string][]byte)
+	for _, entry := range reader.File {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		name, err := entryName(entry.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", entry.Name, err)
+		}
+		files[name] = content
+	}
+	return files, nil
+}
+
+func readTarGz(p string) (map[string][]byte, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = gz.Close()
+	}()
+
+	files := make(map[string][]byte)
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, err := entryName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", header.Name, err)
+		}
+		files[name] = content
+	}
+}
+
+// entryName cleans an entry name and rejects names escaping the archive.
+func entryName(name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("entry %q escapes the archive", name)
+	}
+	return clean, nil
+}
+
+// WriteDir packs the regular files below dir into a new archive at p.
+func WriteDir(p, dir string) error {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("collecting files for %s: %w", p, err)
+	}
+	return Write(p, files)
+}
+
+// Write packs files, keyed by slash-separated path, into a new archive at p.
+// The archive is written next to p and renamed into place, so an existing
+// archive is only replaced once the new one is complete.
+func Write(p string, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	var err error
+	if isZip(p) {
+		err = writeZip(&buf, names, files)
+	} else {
+		err = writeTarGz(&buf, names, files)
+	}
+	if err != nil {
+		return fmt.Errorf("writing archive %s: %w", p, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".proto-migrate-*")
+	if err != nil {
+		return fmt.Errorf("creating temp archive: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing archive %s: %w", p, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing archive %s: %w", p, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("writing archive %s: %w", p, err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("replacing archive %s: %w", p, err)
+	}
+	return nil
+}
+
+func writeZip(w io.Writer, names []string, files map[string][]byte) error {
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+		header.SetMode(0644)
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, names []string, files map[string][]byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		header := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(files[name])),
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
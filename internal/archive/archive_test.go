@@ -0,0 +1,92 @@
+package archive
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteRead(t *testing.T) {
+	files := map[string][]byte{
+		"acme/v1/a.proto":  []byte("syntax = \"proto3\";\npackage acme.v1;\n"),
+		"acme/v1/b.proto":  []byte("syntax = \"proto3\";\npackage acme.v1;\n"),
+		"vendor/x/y.proto": []byte("syntax = \"proto3\";\n"),
+		"README.md":        []byte("not a proto"),
+	}
+
+	for _, name := range []string{"api.zip", "api.tar.gz", "api.tgz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out", name)
+			if err := Write(path, files); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			first, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Read(path)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, files) {
+				t.Errorf("Read() = %v, want %v", got, files)
+			}
+
+			if err := Write(path, files); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			second, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(first) != string(second) {
+				t.Error("writing the same files twice produced different archives")
+			}
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.zip")
+	if err := Write(path, map[string][]byte{"a/b.proto": []byte("syntax = \"proto3\";\n")}); err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewSource(path)
+	paths, err := source.Files(path)
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	want := filepath.Join(path, "a", "b.proto")
+	if len(paths) != 1 || paths[0] != want {
+		t.Fatalf("Files() = %v, want [%s]", paths, want)
+	}
+	if content, err := source.ReadFile(want); err != nil || string(content) != "syntax = \"proto3\";\n" {
+		t.Errorf("ReadFile() = %q, %v", content, err)
+	}
+}
+
+func TestReadRejectsEscapingEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evil.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(file)
+	if _, err := zw.Create("../outside.proto"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Read(path); err == nil {
+		t.Error("Read() accepted an entry outside the archive")
+	}
+}
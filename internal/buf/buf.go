@@ -0,0 +1,197 @@
+package buf
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFile    = "buf.yaml"
+	workspaceFile = "buf.work.yaml"
+)
+
+// Workspace is the set of modules declared by a buf.work.yaml (v1) or a
+// buf.yaml (v1 single module or v2 workspace).
+type Workspace struct {
+	Dir     string
+	Version string
+	Modules []Module
+	Deps    []string
+}
+
+// Module is one buf module. Paths are joined with the workspace directory so
+// they can be used directly as loader roots and include paths.
+type Module struct {
+	Path     string
+	Name     string
+	Excludes []string
+	Deps     []string
+}
+
+type workspaceConfig struct {
+	Version     string   `yaml:"version"`
+	Directories []string `yaml:"directories"`
+}
+
+type moduleConfig struct {
+	Version string   `yaml:"version"`
+	Name    string   `yaml:"name"`
+	Deps    []string `yaml:"deps"`
+	Build   struct {
+		Excludes []string `yaml:"excludes"`
+	} `yaml:"build"`
+	Modules []struct {
+		Path     string   `yaml:"path"`
+		Name     string   `yaml:"name"`
+		Excludes []string `yaml:"excludes"`
+	} `yaml:"modules"`
+}
+
+// Load reads the buf workspace rooted at dir.
+func Load(dir string) (*Workspace, error) {
+	var work workspaceConfig
+	found, err := readYAML(filepath.Join(dir, workspaceFile), &work)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return loadWorkspaceV1(dir, &work)
+	}
+
+	var cfg moduleConfig
+	found, err = readYAML(filepath.Join(dir, configFile), &cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no %s or %s found in %s", configFile, workspaceFile, dir)
+	}
+
+	if cfg.Version == "v2" {
+		return loadV2(dir, &cfg), nil
+	}
+
+	module := moduleV1(dir, &cfg)
+	return &Workspace{
+		Dir:     dir,
+		Version: cfg.Version,
+		Modules: []Module{module},
+		Deps:    module.Deps,
+	}, nil
+}
+
+func loadWorkspaceV1(dir string, work *workspaceConfig) (*Workspace, error) {
+	ws := &Workspace{Dir: dir, Version: work.Version}
+
+	for _, directory := range work.Directories {
+		path := filepath.Join(dir, directory)
+
+		var cfg moduleConfig
+		found, err := readYAML(filepath.Join(path, configFile), &cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		module := Module{Path: path}
+		if found {
+			module = moduleV1(path, &cfg)
+		}
+		ws.Modules = append(ws.Modules, module)
+		ws.Deps = appendUnique(ws.Deps, module.Deps...)
+	}
+
+	return ws, nil
+}
+
+// moduleV1 builds a module from a v1 buf.yaml, whose excludes are relative
+// to the module root.
+func moduleV1(path string, cfg *moduleConfig) Module {
+	module := Module{Path: path, Name: cfg.Name, Deps: cfg.Deps}
+	for _, exclude := range cfg.Build.Excludes {
+		module.Excludes = append(module.Excludes, filepath.Join(path, exclude))
+	}
+	return module
+}
+
+// loadV2 builds a workspace from a v2 buf.yaml, whose module paths and
+// excludes are relative to the directory holding the buf.yaml.
+func loadV2(dir string, cfg *moduleConfig) *Workspace {
+	ws := &Workspace{Dir: dir, Version: cfg.Version, Deps: cfg.Deps}
+
+	if len(cfg.Modules) == 0 {
+		ws.Modules = []Module{{Path: dir, Name: cfg.Name, Deps: cfg.Deps}}
+		return ws
+	}
+
+	for _, m := range cfg.Modules {
+		module := Module{Path: filepath.Join(dir, m.Path), Name: m.Name, Deps: cfg.Deps}
+		for _, exclude := range m.Excludes {
+			module.Excludes = append(module.Excludes, filepath.Join(dir, exclude))
+		}
+		ws.Modules = append(ws.Modules, module)
+	}
+	return ws
+}
+
+// Module returns the module at path, relative to the workspace directory.
+// An empty path selects the only module of a single-module workspace.
+func (w *Workspace) Module(path string) (*Module, error) {
+	if path == "" {
+		if len(w.Modules) != 1 {
+			return nil, fmt.Errorf("workspace %s has %d modules; choose one with buf.module", w.Dir, len(w.Modules))
+		}
+		return &w.Modules[0], nil
+	}
+
+	want := filepath.Join(w.Dir, path)
+	for i := range w.Modules {
+		if w.Modules[i].Path == want {
+			return &w.Modules[i], nil
+		}
+	}
+	return nil, fmt.Errorf("module %s is not part of workspace %s", path, w.Dir)
+}
+
+// Roots returns the directory of every module, for use as include paths.
+func (w *Workspace) Roots() []string {
+	roots := make([]string, 0, len(w.Modules))
+	for _, m := range w.Modules {
+		roots = append(roots, m.Path)
+	}
+	return roots
+}
+
+func readYAML(path string, out any) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(content, out); err != nil {
+		return false, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return true, nil
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		exists := false
+		for _, existing := range list {
+			if existing == value {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			list = append(list, value)
+		}
+	}
+	return list
+}
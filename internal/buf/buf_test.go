@@ -0,0 +1,205 @@
+package buf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		module    string
+		wantPath  string
+		wantName  string
+		wantExcl  []string
+		wantRoots []string
+		wantDeps  []string
+		wantErr   bool
+	}{
+		{
+			name: "v1 workspace",
+			files: map[string]string{
+				"buf.work.yaml":      "version: v1\ndirectories:\n  - proto\n  - vendor\n",
+				"proto/buf.yaml":     "version: v1\nname: buf.build/acme/old\ndeps:\n  - buf.build/googleapis/googleapis\nbuild:\n  excludes:\n    - internal\n",
+				"vendor/placeholder": "",
+			},
+			module:    "proto",
+			wantPath:  "proto",
+			wantName:  "buf.build/acme/old",
+			wantExcl:  []string{"proto/internal"},
+			wantRoots: []string{"proto", "vendor"},
+			wantDeps:  []string{"buf.build/googleapis/googleapis"},
+		},
+		{
+			name: "v1 single module",
+			files: map[string]string{
+				"buf.yaml": "version: v1\nname: buf.build/acme/old\n",
+			},
+			wantPath:  ".",
+			wantName:  "buf.build/acme/old",
+			wantRoots: []string{"."},
+		},
+		{
+			name: "v2 workspace",
+			files: map[string]string{
+				"buf.yaml": "version: v2\nmodules:\n  - path: proto/old\n    name: buf.build/acme/old\n    excludes:\n      - proto/old/internal\n  - path: proto/common\ndeps:\n  - buf.build/bufbuild/protovalidate\n",
+			},
+			module:    "proto/old",
+			wantPath:  "proto/old",
+			wantName:  "buf.build/acme/old",
+			wantExcl:  []string{"proto/old/internal"},
+			wantRoots: []string{"proto/old", "proto/common"},
+			wantDeps:  []string{"buf.build/bufbuild/protovalidate"},
+		},
+		{
+			name: "ambiguous module",
+			files: map[string]string{
+				"buf.yaml": "version: v2\nmodules:\n  - path: a\n  - path: b\n",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no buf config",
+			files:   map[string]string{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+
+			ws, err := Load(dir)
+			var module *Module
+			if err == nil {
+				module, err = ws.Module(tt.module)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if rel := relTo(t, dir, module.Path); rel != tt.wantPath {
+				t.Errorf("module path = %q, want %q", rel, tt.wantPath)
+			}
+			if module.Name != tt.wantName {
+				t.Errorf("module name = %q, want %q", module.Name, tt.wantName)
+			}
+			if got := relAll(t, dir, module.Excludes); !reflect.DeepEqual(got, tt.wantExcl) {
+				t.Errorf("excludes = %v, want %v", got, tt.wantExcl)
+			}
+			if got := relAll(t, dir, ws.Roots()); !reflect.DeepEqual(got, tt.wantRoots) {
+				t.Errorf("roots = %v, want %v", got, tt.wantRoots)
+			}
+			if !reflect.DeepEqual(ws.Deps, tt.wantDeps) {
+				t.Errorf("deps = %v, want %v", ws.Deps, tt.wantDeps)
+			}
+		})
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	t.Run("v2 new module", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"buf.yaml": `version: v2
+modules:
+  - path: proto/old
+    name: buf.build/acme/old
+    excludes:
+      - proto/old/internal
+lint:
+  ignore:
+    - proto/old/legacy.proto
+`,
+		})
+		ws, err := Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = UpdateConfig(ws, filepath.Join(dir, "proto/old"), filepath.Join(dir, "proto/new"), "buf.build/acme/new")
+		if err != nil {
+			t.Fatalf("UpdateConfig() error = %v", err)
+		}
+
+		got := readFile(t, filepath.Join(dir, "buf.yaml"))
+		for _, want := range []string{
+			"  - path: proto/new\n    name: buf.build/acme/new\n    excludes:\n      - proto/new/internal\n",
+			"    - proto/old/legacy.proto\n    - proto/new/legacy.proto\n",
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("buf.yaml missing %q:\n%s", want, got)
+			}
+		}
+	})
+
+	t.Run("v1 workspace", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"buf.work.yaml": "version: v1\ndirectories:\n  - old\n",
+			"old/buf.yaml":  "version: v1\nname: buf.build/acme/old\n",
+		})
+		ws, err := Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = UpdateConfig(ws, filepath.Join(dir, "old"), filepath.Join(dir, "new"), "buf.build/acme/new")
+		if err != nil {
+			t.Fatalf("UpdateConfig() error = %v", err)
+		}
+
+		if got := readFile(t, filepath.Join(dir, "new/buf.yaml")); !strings.Contains(got, "name: buf.build/acme/new") {
+			t.Errorf("new/buf.yaml = %q, want new module name", got)
+		}
+		if got := readFile(t, filepath.Join(dir, "buf.work.yaml")); !strings.Contains(got, "  - old\n  - new\n") {
+			t.Errorf("buf.work.yaml = %q, want new directory listed", got)
+		}
+	})
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func relTo(t *testing.T, base, path string) string {
+	t.Helper()
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func relAll(t *testing.T, base string, paths []string) []string {
+	var rels []string
+	for _, path := range paths {
+		rels = append(rels, relTo(t, base, path))
+	}
+	return rels
+}
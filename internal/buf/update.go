@@ -0,0 +1,290 @@
+package buf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UpdateConfig records a migrated module in the buf configuration. The
+// module at source is renamed in place when target equals source; otherwise
+// a module at target is declared with the source module's settings, its
+// exclude and ignore paths moved along with it. An empty name keeps the
+// source name for in-place migrations and leaves a new module unnamed.
+func UpdateConfig(w *Workspace, source, target, name string) error {
+	if w.Version == "v2" {
+		return updateV2(w, source, target, name)
+	}
+	return updateV1(w, source, target, name)
+}
+
+func updateV1(w *Workspace, source, target, name string) error {
+	doc, err := readNode(filepath.Join(source, configFile))
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		doc = newDocument()
+		setScalar(doc.Content[0], "version", "v1")
+	}
+	root := doc.Content[0]
+
+	if name != "" {
+		setScalar(root, "name", name)
+	} else if target != source {
+		deleteKey(root, "name")
+	}
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("creating target directory: %w", err)
+	}
+	if err := writeNode(filepath.Join(target, configFile), doc); err != nil {
+		return err
+	}
+
+	if target == source {
+		return nil
+	}
+
+	work, err := readNode(filepath.Join(w.Dir, workspaceFile))
+	if err != nil || work == nil {
+		return err
+	}
+	rel, err := filepath.Rel(w.Dir, target)
+	if err != nil {
+		return fmt.Errorf("locating %s in workspace: %w", target, err)
+	}
+	directories := mappingValue(work.Content[0], "directories")
+	if directories == nil {
+		directories = &yaml.Node{Kind: yaml.SequenceNode}
+		appendKey(work.Content[0], "directories", directories)
+	}
+	if !containsScalar(directories, filepath.ToSlash(rel)) {
+		directories.Content = append(directories.Content, scalar(filepath.ToSlash(rel)))
+	}
+	return writeNode(filepath.Join(w.Dir, workspaceFile), work)
+}
+
+func updateV2(w *Workspace, source, target, name string) error {
+	path := filepath.Join(w.Dir, configFile)
+	doc, err := readNode(path)
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		return fmt.Errorf("%s not found", path)
+	}
+	root := doc.Content[0]
+
+	sourceRel, err := relSlash(w.Dir, source)
+	if err != nil {
+		return err
+	}
+	targetRel, err := relSlash(w.Dir, target)
+	if err != nil {
+		return err
+	}
+
+	modules := mappingValue(root, "modules")
+	if modules == nil {
+		// A v2 buf.yaml without modules is a single module at its root.
+		if name != "" {
+			setScalar(root, "name", name)
+		}
+		return writeNode(path, doc)
+	}
+
+	var module *yaml.Node
+	for _, m := range modules.Content {
+		if p := mappingValue(m, "path"); p != nil && filepath.Clean(p.Value) == sourceRel {
+			module = m
+			break
+		}
+	}
+	if module == nil {
+		return fmt.Errorf("module %s is not declared in %s", sourceRel, path)
+	}
+
+	if targetRel == sourceRel {
+		if name != "" {
+			setScalar(module, "name", name)
+		}
+		return writeNode(path, doc)
+	}
+
+	remap := func(p string) (string, bool) {
+		if p == sourceRel {
+			return targetRel, true
+		}
+		if strings.HasPrefix(p, sourceRel+"/") {
+			return targetRel + strings.TrimPrefix(p, sourceRel), true
+		}
+		return p, false
+	}
+
+	clone := cloneNode(module)
+	setScalar(clone, "path", targetRel)
+	if name != "" {
+		setScalar(clone, "name", name)
+	} else {
+		deleteKey(clone, "name")
+	}
+	remapPaths(clone, remap, false)
+	modules.Content = append(modules.Content, clone)
+
+	// Top-level lint and breaking ignores apply to every module, so the
+	// target module gets its own copy of entries that covered the source.
+	remapPaths(root, remap, true)
+
+	return writeNode(path, doc)
+}
+
+// remapPaths rewrites excludes and lint/breaking ignore entries below node.
+// With keep set the original entries stay and remapped copies are added.
+func remapPaths(node *yaml.Node, remap func(string) (string, bool), keep bool) {
+	remapList := func(list *yaml.Node) {
+		if list == nil || list.Kind != yaml.SequenceNode {
+			return
+		}
+		var extra []*yaml.Node
+		for _, item := range list.Content {
+			mapped, ok := remap(item.Value)
+			if !ok {
+				continue
+			}
+			if keep {
+				extra = append(extra, scalar(mapped))
+			} else {
+				item.Value = mapped
+			}
+		}
+		list.Content = append(list.Content, extra...)
+	}
+
+	if !keep {
+		remapList(mappingValue(node, "excludes"))
+	}
+	for _, section := range []string{"lint", "breaking"} {
+		s := mappingValue(node, section)
+		if s == nil {
+			continue
+		}
+		remapList(mappingValue(s, "ignore"))
+		if only := mappingValue(s, "ignore_only"); only != nil && only.Kind == yaml.MappingNode {
+			for i := 1; i < len(only.Content); i += 2 {
+				remapList(only.Content[i])
+			}
+		}
+	}
+}
+
+func relSlash(base, path string) (string, error) {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return "", fmt.Errorf("locating %s in workspace: %w", path, err)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func readNode(path string) (*yaml.Node, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return newDocument(), nil
+	}
+	return &doc, nil
+}
+
+func writeNode(path string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("encoding %s: %w", path, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("encoding %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+func newDocument() *yaml.Node {
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setScalar(node *yaml.Node, key, value string) {
+	if existing := mappingValue(node, key); existing != nil {
+		existing.Kind = yaml.ScalarNode
+		existing.Tag = "!!str"
+		existing.Value = value
+		existing.Content = nil
+		return
+	}
+	appendKey(node, key, scalar(value))
+}
+
+func appendKey(node *yaml.Node, key string, value *yaml.Node) {
+	node.Content = append(node.Content, scalar(key), value)
+}
+
+func deleteKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func containsScalar(list *yaml.Node, value string) bool {
+	for _, item := range list.Content {
+		if filepath.Clean(item.Value) == filepath.Clean(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneNode(node *yaml.Node) *yaml.Node {
+	clone := *node
+	clone.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		clone.Content[i] = cloneNode(child)
+	}
+	return &clone
+}
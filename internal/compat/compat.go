@@ -0,0 +1,365 @@
+package compat
+
+import (
+	"fmt"
+	"sort"
+	"text/scanner"
+
+	"github.com/emicklei/proto"
+	"github.com/jackchuka/proto-migrate/internal/loader"
+	"github.com/jackchuka/proto-migrate/internal/resolve"
+)
+
+type Severity string
+
+const (
+	// SeverityWire marks changes that break the binary wire format.
+	SeverityWire Severity = "wire"
+	// SeverityJSON marks changes that are safe on the wire but alter the
+	// protojson or text encoding, including Any type URLs.
+	SeverityJSON Severity = "json"
+)
+
+// typeURLPrefix is the prefix protobuf runtimes use when packing an Any.
+const typeURLPrefix = "type.googleapis.com/"
+
+// Issue is a single incompatibility between the original and migrated schema.
+type Issue struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Severity Severity `json:"severity"`
+	Element  string   `json:"element"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s:%d: [%s] %s: %s", i.File, i.Line, i.Severity, i.Element, i.Message)
+}
+
+// File pairs the original and migrated definitions of one proto file.
+type File struct {
+	Path     string
+	Original *proto.Proto
+	Migrated *proto.Proto
+}
+
+// Check compares every message and enum of the original files with its
+// migrated counterpart. Declarations are paired by name first and then by
+// position, so renames alone are never reported.
+func Check(files []File) []Issue {
+	c := &checker{
+		original: newSchema(files, func(f File) *proto.Proto { return f.Original }),
+		migrated: newSchema(files, func(f File) *proto.Proto { return f.Migrated }),
+		renames:  make(map[string]string),
+	}
+
+	// Pair all declarations up front so that field types referring to a
+	// renamed message in another file are recognised.
+	for _, f := range files {
+		c.collectRenames(packageName(f.Original), packageName(f.Migrated), f.Original.Elements, f.Migrated.Elements)
+	}
+	for _, f := range files {
+		c.path = f.Path
+		c.compareElements(packageName(f.Original), packageName(f.Migrated), f.Original.Elements, f.Migrated.Elements)
+	}
+
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		if c.issues[i].File != c.issues[j].File {
+			return c.issues[i].File < c.issues[j].File
+		}
+		return c.issues[i].Line < c.issues[j].Line
+	})
+	return c.issues
+}
+
+// HasSeverity reports whether any issue has the given severity.
+func HasSeverity(issues []Issue, severity Severity) bool {
+	for _, issue := range issues {
+		if issue.Severity == severity {
+			return true
+		}
+	}
+	return false
+}
+
+type checker struct {
+	path     string
+	original *resolve.Graph
+	migrated *resolve.Graph
+	// renames maps the fully-qualified name of every original message and
+	// enum to that of its migrated counterpart.
+	renames map[string]string
+	issues  []Issue
+}
+
+func (c *checker) report(pos scanner.Position, severity Severity, element, format string, args ...any) {
+	c.issues = append(c.issues, Issue{
+		File:     c.path,
+		Line:     pos.Line,
+		Severity: severity,
+		Element:  element,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) collectRenames(origScope, migScope string, original, migrated []proto.Visitee) {
+	origMessages, migMessages := messages(original), messages(migrated)
+	for _, p := range pairByName(origMessages, migMessages, func(m *proto.Message) string { return m.Name }) {
+		if p.migrated < 0 {
+			continue
+		}
+		o, m := origMessages[p.original], migMessages[p.migrated]
+		origName, migName := resolve.Qualify(origScope, o.Name), resolve.Qualify(migScope, m.Name)
+		c.renames[origName] = migName
+		c.collectRenames(origName, migName, o.Elements, m.Elements)
+	}
+
+	origEnums, migEnums := enums(original), enums(migrated)
+	for _, p := range pairByName(origEnums, migEnums, func(e *proto.Enum) string { return e.Name }) {
+		if p.migrated >= 0 {
+			c.renames[resolve.Qualify(origScope, origEnums[p.original].Name)] = resolve.Qualify(migScope, migEnums[p.migrated].Name)
+		}
+	}
+}
+
+func (c *checker) compareElements(origScope, migScope string, original, migrated []proto.Visitee) {
+	origMessages, migMessages := messages(original), messages(migrated)
+	for _, p := range pairByName(origMessages, migMessages, func(m *proto.Message) string { return m.Name }) {
+		o := origMessages[p.original]
+		if p.migrated < 0 {
+			c.report(o.Position, SeverityWire, resolve.Qualify(origScope, o.Name), "message removed")
+			continue
+		}
+		m := migMessages[p.migrated]
+		c.compareMessage(resolve.Qualify(origScope, o.Name), resolve.Qualify(migScope, m.Name), o, m)
+	}
+
+	origEnums, migEnums := enums(original), enums(migrated)
+	for _, p := range pairByName(origEnums, migEnums, func(e *proto.Enum) string { return e.Name }) {
+		o := origEnums[p.original]
+		if p.migrated < 0 {
+			c.report(o.Position, SeverityWire, resolve.Qualify(origScope, o.Name), "enum removed")
+			continue
+		}
+		c.compareEnum(resolve.Qualify(migScope, migEnums[p.migrated].Name), o, migEnums[p.migrated])
+	}
+}
+
+func (c *checker) compareMessage(origName, name string, original, migrated *proto.Message) {
+	if origName != name {
+		c.report(migrated.Position, SeverityJSON, name, "Any type URL changes from %s%s to %s%s", typeURLPrefix, origName, typeURLPrefix, name)
+	}
+
+	orig, mig := collectFields(original), collectFields(migrated)
+
+	for _, number := range orig.numbers() {
+		of := orig.byNumber[number]
+		element := name + "." + of.name
+
+		mf, ok := mig.byNumber[number]
+		if !ok {
+			if renumbered, ok := mig.byName[of.name]; ok {
+				c.report(renumbered.pos, SeverityWire, element, "field number changed from %d to %d", of.number, renumbered.number)
+			} else if !mig.reserves(number) {
+				c.report(of.pos, SeverityWire, element, "field %d removed without being reserved", number)
+			}
+			continue
+		}
+
+		if !c.sameType(origName, name, of, mf) {
+			c.report(mf.pos, SeverityWire, element, "type changed from %s to %s", of.typeString(), mf.typeString())
+		}
+		if of.label != mf.label {
+			c.report(mf.pos, SeverityWire, element, "label changed from %s to %s", labelString(of.label), labelString(mf.label))
+		}
+		if msg := c.oneofChange(orig, mig, of, mf); msg != "" {
+			c.report(mf.pos, SeverityWire, element, "%s", msg)
+		}
+
+		switch {
+		case of.jsonName != mf.jsonName:
+			c.report(mf.pos, SeverityJSON, element, "JSON name changes from %s to %s", of.jsonName, mf.jsonName)
+		case of.name != mf.name:
+			c.report(mf.pos, SeverityJSON, element, "text format name changes from %s to %s", of.name, mf.name)
+		}
+	}
+
+	c.compareElements(origName, name, original.Elements, migrated.Elements)
+}
+
+// oneofChange describes how the oneof membership of a field changed, if it did.
+func (c *checker) oneofChange(orig, mig *fieldSet, of, mf *field) string {
+	switch {
+	case of.oneof == "" && mf.oneof != "":
+		return fmt.Sprintf("moved into oneof %s", mf.oneof)
+	case of.oneof != "" && mf.oneof == "":
+		return fmt.Sprintf("moved out of oneof %s", of.oneof)
+	case of.oneof == "":
+		return ""
+	}
+
+	for _, number := range orig.oneofs[of.oneof] {
+		if sibling, ok := mig.byNumber[number]; ok && sibling.oneof != "" && sibling.oneof != mf.oneof {
+			return fmt.Sprintf("no longer shares oneof %s with field %d", mf.oneof, number)
+		}
+	}
+	return ""
+}
+
+func (c *checker) compareEnum(name string, original, migrated *proto.Enum) {
+	origValues, migValues := enumValues(original), enumValues(migrated)
+
+	migByName := make(map[string]*proto.EnumField)
+	migByNumber := make(map[int]*proto.EnumField)
+	for _, v := range migValues {
+		migByName[v.Name] = v
+		if _, exists := migByNumber[v.Integer]; !exists {
+			migByNumber[v.Integer] = v
+		}
+	}
+	reserved := reservedIn(migrated.Elements)
+
+	for _, ov := range origValues {
+		element := name + "." + ov.Name
+		if mv, ok := migByName[ov.Name]; ok {
+			if mv.Integer != ov.Integer {
+				c.report(mv.Position, SeverityWire, element, "enum value number changed from %d to %d", ov.Integer, mv.Integer)
+			}
+			continue
+		}
+		if mv, ok := migByNumber[ov.Integer]; ok {
+			c.report(mv.Position, SeverityJSON, element, "enum value name changes from %s to %s", ov.Name, mv.Name)
+			continue
+		}
+		if !reserved.contains(ov.Integer) {
+			c.report(ov.Position, SeverityWire, element, "enum value %d removed without being reserved", ov.Integer)
+		}
+	}
+}
+
+// sameType reports whether two fields, declared in the messages origScope
+// and migScope, have the same type once both are resolved to fully-qualified
+// names. Types declared outside the checked files must be written alike.
+func (c *checker) sameType(origScope, migScope string, of, mf *field) bool {
+	if of.keyType != mf.keyType {
+		return false
+	}
+	if resolve.IsScalar(of.typ) || resolve.IsScalar(mf.typ) {
+		return of.typ == mf.typ
+	}
+
+	o, oKnown := c.original.ResolveType(of.typ, origScope)
+	m, mKnown := c.migrated.ResolveType(mf.typ, migScope)
+	if oKnown != mKnown {
+		return false
+	}
+	if !oKnown {
+		return o == m
+	}
+	if isEnum(c.original, o) != isEnum(c.migrated, m) {
+		return false
+	}
+	if renamed, ok := c.renames[o]; ok {
+		return renamed == m
+	}
+	return o == m
+}
+
+// pairing holds indexes into the original and migrated slices; migrated is
+// -1 when the original declaration has no counterpart.
+type pairing struct {
+	original int
+	migrated int
+}
+
+// pairByName pairs declarations with equal names, then pairs whatever is
+// left in declaration order.
+func pairByName[T any](original, migrated []T, name func(T) string) []pairing {
+	pairs := make([]pairing, len(original))
+	used := make([]bool, len(migrated))
+
+	byName := make(map[string]int, len(migrated))
+	for i, m := range migrated {
+		byName[name(m)] = i
+	}
+	for i, o := range original {
+		pairs[i] = pairing{original: i, migrated: -1}
+		if j, ok := byName[name(o)]; ok && !used[j] {
+			pairs[i].migrated = j
+			used[j] = true
+		}
+	}
+
+	next := 0
+	for i := range pairs {
+		if pairs[i].migrated >= 0 {
+			continue
+		}
+		for next < len(migrated) && used[next] {
+			next++
+		}
+		if next < len(migrated) {
+			pairs[i].migrated = next
+			used[next] = true
+		}
+	}
+	return pairs
+}
+
+func messages(elements []proto.Visitee) []*proto.Message {
+	var result []*proto.Message
+	for _, element := range elements {
+		if m, ok := element.(*proto.Message); ok && !m.IsExtend {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func enums(elements []proto.Visitee) []*proto.Enum {
+	var result []*proto.Enum
+	for _, element := range elements {
+		if e, ok := element.(*proto.Enum); ok {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func enumValues(e *proto.Enum) []*proto.EnumField {
+	var result []*proto.EnumField
+	for _, element := range e.Elements {
+		if v, ok := element.(*proto.EnumField); ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// newSchema indexes the definitions of one side of the comparison.
+func newSchema(files []File, definition func(File) *proto.Proto) *resolve.Graph {
+	g := resolve.NewGraph()
+	for _, f := range files {
+		g.AddFile(&loader.ProtoFile{Path: f.Path, Proto: definition(f)})
+	}
+	return g
+}
+
+// isEnum reports whether the fully-qualified name is an enum in g.
+func isEnum(g *resolve.Graph, name string) bool {
+	for _, s := range g.Lookup(name) {
+		if s.Kind == resolve.KindEnum {
+			return true
+		}
+	}
+	return false
+}
+
+func packageName(definition *proto.Proto) string {
+	for _, element := range definition.Elements {
+		if p, ok := element.(*proto.Package); ok {
+			return p.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,180 @@
+package compat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emicklei/proto"
+)
+
+func TestCheck(t *testing.T) {
+	original := `syntax = "proto3";
+
+package old.v1;
+
+message Item {
+  string id = 1;
+  string name = 2;
+  Status status = 3;
+  oneof owner {
+    string user = 4;
+    string team = 5;
+  }
+  int32 legacy = 6;
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_OK = 1;
+  STATUS_GONE = 2;
+}`
+
+	tests := []struct {
+		name     string
+		migrated string
+		want     []string
+	}{
+		{
+			name:     "package rename only",
+			migrated: strings.ReplaceAll(original, "old.v1", "new.v1"),
+			want:     []string{"[json] new.v1.Item: Any type URL changes from type.googleapis.com/old.v1.Item to type.googleapis.com/new.v1.Item"},
+		},
+		{
+			name:     "message and enum renames",
+			migrated: strings.NewReplacer("message Item", "message Entry", "Status", "State").Replace(original),
+			want:     []string{"[json] old.v1.Entry: Any type URL changes"},
+		},
+		{
+			name:     "field renumbered",
+			migrated: strings.Replace(original, "string name = 2;", "string name = 7;", 1),
+			want:     []string{"[wire] old.v1.Item.name: field number changed from 2 to 7"},
+		},
+		{
+			name:     "field type changed",
+			migrated: strings.Replace(original, "string name = 2;", "bytes name = 2;", 1),
+			want:     []string{"[wire] old.v1.Item.name: type changed from string to bytes"},
+		},
+		{
+			name:     "field removed without reserved",
+			migrated: strings.Replace(original, "  int32 legacy = 6;\n", "", 1),
+			want:     []string{"[wire] old.v1.Item.legacy: field 6 removed without being reserved"},
+		},
+		{
+			name:     "field removed with reserved",
+			migrated: strings.Replace(original, "  int32 legacy = 6;\n", "  reserved 6;\n", 1),
+		},
+		{
+			name:     "field moved out of oneof",
+			migrated: strings.Replace(original, "    string team = 5;\n  }", "  }\n  string team = 5;", 1),
+			want:     []string{"[wire] old.v1.Item.team: moved out of oneof owner"},
+		},
+		{
+			name:     "enum value renumbered",
+			migrated: strings.Replace(original, "STATUS_GONE = 2;", "STATUS_GONE = 3;", 1),
+			want:     []string{"[wire] old.v1.Status.STATUS_GONE: enum value number changed from 2 to 3"},
+		},
+		{
+			name:     "enum value renamed",
+			migrated: strings.Replace(original, "STATUS_GONE = 2;", "STATUS_DELETED = 2;", 1),
+			want:     []string{"[json] old.v1.Status.STATUS_GONE: enum value name changes from STATUS_GONE to STATUS_DELETED"},
+		},
+		{
+			name:     "field renamed",
+			migrated: strings.Replace(original, "string name = 2;", "string display_name = 2;", 1),
+			want:     []string{"[json] old.v1.Item.name: JSON name changes from name to displayName"},
+		},
+		{
+			name:     "field renamed with pinned json_name",
+			migrated: strings.Replace(original, "string name = 2;", `string title = 2 [json_name = "name"];`, 1),
+			want:     []string{"[json] old.v1.Item.name: text format name changes from name to title"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := Check([]File{{
+				Path:     "item.proto",
+				Original: parseProto(t, original),
+				Migrated: parseProto(t, tt.migrated),
+			}})
+
+			if len(issues) != len(tt.want) {
+				t.Fatalf("Check() returned %d issues, want %d: %v", len(issues), len(tt.want), issues)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(issues[i].String(), want) {
+					t.Errorf("issue %d = %q, want it to contain %q", i, issues[i].String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckResolvesTypes(t *testing.T) {
+	a := `syntax = "proto3";
+package a;
+message Item {}
+message Holder {
+  message Item {}
+}`
+	b := `syntax = "proto3";
+package b;
+message Item {}`
+	user := `syntax = "proto3";
+package c;
+message Order {
+  a.Item item = 1;
+  a.Holder.Item nested = 2;
+}`
+
+	tests := []struct {
+		name string
+		user string
+		want []string
+	}{
+		{
+			name: "unchanged",
+			user: user,
+		},
+		{
+			name: "same short name in another package",
+			user: strings.Replace(user, "a.Item item", "b.Item item", 1),
+			want: []string{"[wire] c.Order.item: type changed from a.Item to b.Item"},
+		},
+		{
+			name: "nested message with a top-level name",
+			user: strings.Replace(user, "a.Holder.Item nested", "a.Item nested", 1),
+			want: []string{"[wire] c.Order.nested: type changed from a.Holder.Item to a.Item"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files []File
+			for path, content := range map[string][2]string{"a.proto": {a, a}, "b.proto": {b, b}, "c.proto": {user, tt.user}} {
+				files = append(files, File{Path: path, Original: parseProto(t, content[0]), Migrated: parseProto(t, content[1])})
+			}
+
+			issues := Check(files)
+			if len(issues) != len(tt.want) {
+				t.Fatalf("Check() returned %d issues, want %d: %v", len(issues), len(tt.want), issues)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(issues[i].String(), want) {
+					t.Errorf("issue %d = %q, want it to contain %q", i, issues[i].String(), want)
+				}
+			}
+		})
+	}
+}
+
+func parseProto(t *testing.T, content string) *proto.Proto {
+	t.Helper()
+	reader := strings.NewReader(content)
+	parser := proto.NewParser(reader)
+	definition, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse proto: %v", err)
+	}
+	return definition
+}
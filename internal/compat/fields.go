@@ -0,0 +1,173 @@
+package compat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/scanner"
+
+	"github.com/emicklei/proto"
+)
+
+type field struct {
+	name     string
+	jsonName string
+	number   int
+	typ      string
+	keyType  string
+	label    string
+	oneof    string
+	pos      scanner.Position
+}
+
+func (f *field) typeString() string {
+	if f.keyType != "" {
+		return fmt.Sprintf("map<%s, %s>", f.keyType, f.typ)
+	}
+	return f.typ
+}
+
+func labelString(label string) string {
+	if label == "" {
+		return "singular"
+	}
+	return label
+}
+
+type fieldSet struct {
+	byNumber map[int]*field
+	byName   map[string]*field
+	oneofs   map[string][]int
+	reserved reservation
+}
+
+func (s *fieldSet) numbers() []int {
+	numbers := make([]int, 0, len(s.byNumber))
+	for number := range s.byNumber {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+func (s *fieldSet) reserves(number int) bool {
+	return s.reserved.contains(number)
+}
+
+func (s *fieldSet) add(f *field) {
+	s.byNumber[f.number] = f
+	s.byName[f.name] = f
+	if f.oneof != "" {
+		s.oneofs[f.oneof] = append(s.oneofs[f.oneof], f.number)
+	}
+}
+
+func collectFields(m *proto.Message) *fieldSet {
+	s := &fieldSet{
+		byNumber: make(map[int]*field),
+		byName:   make(map[string]*field),
+		oneofs:   make(map[string][]int),
+		reserved: reservedIn(m.Elements),
+	}
+
+	for _, element := range m.Elements {
+		switch e := element.(type) {
+		case *proto.NormalField:
+			s.add(&field{name: e.Name, jsonName: jsonName(e.Field), number: e.Sequence, typ: e.Type, label: normalLabel(e), pos: e.Position})
+		case *proto.MapField:
+			s.add(&field{name: e.Name, jsonName: jsonName(e.Field), number: e.Sequence, typ: e.Type, keyType: e.KeyType, pos: e.Position})
+		case *proto.Group:
+			name := strings.ToLower(e.Name)
+			s.add(&field{name: name, jsonName: defaultJSONName(name), number: e.Sequence, typ: e.Name, label: groupLabel(e), pos: e.Position})
+		case *proto.Oneof:
+			for _, member := range e.Elements {
+				switch f := member.(type) {
+				case *proto.OneOfField:
+					s.add(&field{name: f.Name, jsonName: jsonName(f.Field), number: f.Sequence, typ: f.Type, oneof: e.Name, pos: f.Position})
+				case *proto.Group:
+					name := strings.ToLower(f.Name)
+					s.add(&field{name: name, jsonName: defaultJSONName(name), number: f.Sequence, typ: f.Name, oneof: e.Name, pos: f.Position})
+				}
+			}
+		}
+	}
+	return s
+}
+
+// jsonName returns the protojson name of a field: its json_name option if
+// set, otherwise the lowerCamelCase form protoc derives from the field name.
+func jsonName(f *proto.Field) string {
+	for _, o := range f.Options {
+		if o.Name == "json_name" {
+			return o.Constant.Source
+		}
+	}
+	return defaultJSONName(f.Name)
+}
+
+func defaultJSONName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalLabel returns the wire-relevant label of a field. proto3 optional
+// only affects presence tracking and is therefore not part of it.
+func normalLabel(f *proto.NormalField) string {
+	switch {
+	case f.Repeated:
+		return "repeated"
+	case f.Required:
+		return "required"
+	}
+	return ""
+}
+
+func groupLabel(g *proto.Group) string {
+	switch {
+	case g.Repeated:
+		return "repeated"
+	case g.Required:
+		return "required"
+	}
+	return ""
+}
+
+// reservation collects the reserved numbers and names of a message or enum.
+type reservation struct {
+	ranges []proto.Range
+	names  map[string]bool
+}
+
+func reservedIn(elements []proto.Visitee) reservation {
+	r := reservation{names: make(map[string]bool)}
+	for _, element := range elements {
+		if reserved, ok := element.(*proto.Reserved); ok {
+			r.ranges = append(r.ranges, reserved.Ranges...)
+			for _, name := range reserved.FieldNames {
+				r.names[name] = true
+			}
+		}
+	}
+	return r
+}
+
+func (r reservation) contains(number int) bool {
+	for _, rng := range r.ranges {
+		if number >= rng.From && (rng.Max || number <= rng.To) {
+			return true
+		}
+	}
+	return false
+}
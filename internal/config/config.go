@@ -5,15 +5,68 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/jackchuka/proto-migrate/internal/buf"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Source   string   `yaml:"source"`
-	Target   string   `yaml:"target"`
-	Excludes []string `yaml:"excludes"`
-	Rules    []Rule   `yaml:"rules"`
+	Source       string   `yaml:"source"`
+	Target       string   `yaml:"target"`
+	IncludePaths []string `yaml:"include_paths"`
+	Includes     []string `yaml:"includes"`
+	Excludes     []string `yaml:"excludes"`
+	Consumers    []string `yaml:"consumers"`
+	Symlinks     string   `yaml:"symlinks"`
+	AllowCycles  bool     `yaml:"allow_cycles"`
+	Rules        []Rule   `yaml:"rules"`
+	Buf          *Buf     `yaml:"buf,omitempty"`
+	Vendor       Vendor   `yaml:"vendor"`
+
+	// SourceRev is the git revision the source is read from, taken from a
+	// "git://<rev>:<path>" source.
+	SourceRev string `yaml:"-"`
+
+	workspace *buf.Workspace
+}
+
+// Buf selects a module of a buf workspace as the migration source. Its
+// directory becomes the default source, every module root becomes an
+// include path and the module's excludes are honoured.
+type Buf struct {
+	Path         string `yaml:"path"`
+	Module       string `yaml:"module"`
+	Target       string `yaml:"target"`
+	Name         string `yaml:"name"`
+	UpdateConfig bool   `yaml:"update_config"`
+	// InPlace migrates the module into its own directory when no target is
+	// given; without it a target is required.
+	InPlace bool `yaml:"in_place"`
+}
+
+// Vendor configures where --vendor-deps finds external imports.
+type Vendor struct {
+	// Paths are local directories searched, in order, before the
+	// well-known types embedded in the binary.
+	Paths []string `yaml:"paths"`
+	// Sources are tried before Paths for the imports below their prefix,
+	// longest prefix first.
+	Sources []VendorSource `yaml:"sources"`
+}
+
+// VendorSource fetches the imports below Prefix from exactly one of a local
+// directory, a URL template or a git repository.
+type VendorSource struct {
+	Prefix string `yaml:"prefix"`
+	Dir    string `yaml:"dir,omitempty"`
+	// URL is expanded with {path}, the import path, and {ref}.
+	URL string `yaml:"url,omitempty"`
+	Git string `yaml:"git,omitempty"`
+	Ref string `yaml:"ref,omitempty"`
+	// Subdir is the directory of the git repository that import paths are
+	// relative to.
+	Subdir string `yaml:"subdir,omitempty"`
 }
 
 type Rule struct {
@@ -50,6 +103,23 @@ func parse(r io.Reader) (*Config, error) {
 		return nil, fmt.Errorf("parsing yaml: %w", err)
 	}
 
+	if rest, ok := strings.CutPrefix(cfg.Source, "git://"); ok {
+		rev, path, found := strings.Cut(rest, ":")
+		if !found || rev == "" {
+			return nil, fmt.Errorf("git source %q must have the form git://<rev>:<path>", cfg.Source)
+		}
+		if path == "" {
+			path = "."
+		}
+		cfg.SourceRev, cfg.Source = rev, path
+	}
+
+	if cfg.Buf != nil {
+		if err := cfg.applyBuf(); err != nil {
+			return nil, fmt.Errorf("reading buf workspace: %w", err)
+		}
+	}
+
 	if err := cfg.validate(); err != nil {
 		return nil, fmt.Errorf("validating config: %w", err)
 	}
@@ -57,6 +127,50 @@ func parse(r io.Reader) (*Config, error) {
 	return &cfg, nil
 }
 
+// applyBuf fills source, target, include paths and excludes from the buf
+// workspace. Values set explicitly in the config take precedence.
+func (c *Config) applyBuf() error {
+	dir := c.Buf.Path
+	if dir == "" {
+		dir = "."
+	}
+
+	ws, err := buf.Load(dir)
+	if err != nil {
+		return err
+	}
+	module, err := ws.Module(c.Buf.Module)
+	if err != nil {
+		return err
+	}
+
+	if c.Source == "" {
+		c.Source = module.Path
+	}
+	if c.Target == "" {
+		switch {
+		case c.Buf.Target != "":
+			c.Target = filepath.Join(dir, c.Buf.Target)
+		case c.Buf.InPlace:
+			c.Target = c.Source
+		default:
+			return fmt.Errorf("no target: set target or buf.target, or buf.in_place to rewrite module %s in place", module.Path)
+		}
+	}
+	c.IncludePaths = append(c.IncludePaths, ws.Roots()...)
+	for _, exclude := range module.Excludes {
+		c.Excludes = append(c.Excludes, filepath.ToSlash(exclude)+"/**")
+	}
+
+	c.workspace = ws
+	return nil
+}
+
+// Workspace returns the buf workspace the config was resolved against, or nil.
+func (c *Config) Workspace() *buf.Workspace {
+	return c.workspace
+}
+
 func (c *Config) validate() error {
 	if c.Source == "" {
 		return fmt.Errorf("source directory is required")
@@ -65,12 +179,46 @@ func (c *Config) validate() error {
 		return fmt.Errorf("target directory is required")
 	}
 
+	switch c.Symlinks {
+	case "", "follow", "skip", "error":
+	default:
+		return fmt.Errorf("symlinks must be follow, skip or error, got %q", c.Symlinks)
+	}
+
 	for i, rule := range c.Rules {
 		if err := rule.validate(); err != nil {
 			return fmt.Errorf("rule %d: %w", i, err)
 		}
 	}
 
+	for i, source := range c.Vendor.Sources {
+		if err := source.validate(); err != nil {
+			return fmt.Errorf("vendor source %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *VendorSource) validate() error {
+	kinds := 0
+	for _, location := range []string{s.Dir, s.URL, s.Git} {
+		if location != "" {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("exactly one of 'dir', 'url' and 'git' is required")
+	}
+	if s.URL != "" && !strings.Contains(s.URL, "{path}") {
+		return fmt.Errorf("url %q must contain {path}", s.URL)
+	}
+	if strings.Contains(s.URL, "{ref}") && s.Ref == "" {
+		return fmt.Errorf("url %q requires a pinned 'ref'", s.URL)
+	}
+	if s.Git != "" && s.Ref == "" {
+		return fmt.Errorf("git source requires a pinned 'ref'")
+	}
 	return nil
 }
 
@@ -84,7 +232,7 @@ func (r *Rule) validate() error {
 		if r.Pattern == "" || r.Replace == "" {
 			return fmt.Errorf("regexp rule requires 'pattern' and 'replace' fields")
 		}
-	case "import", "option":
+	case "import", "option", "relocate":
 		if r.From == "" || r.To == "" {
 			return fmt.Errorf("%s rule requires 'from' and 'to' fields", r.Kind)
 		}
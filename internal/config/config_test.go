@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -32,6 +34,83 @@ rules:
   - kind: package
     from: old.v1
     to: new.v1
+`,
+			wantErr: true,
+		},
+		{
+			name: "git source",
+			yaml: `
+source: git://main:proto/old
+target: proto/new
+`,
+		},
+		{
+			name: "git source without rev",
+			yaml: `
+source: git://proto/old
+target: proto/new
+`,
+			wantErr: true,
+		},
+		{
+			name: "invalid symlink policy",
+			yaml: `
+source: proto/old
+target: proto/new
+symlinks: sometimes
+`,
+			wantErr: true,
+		},
+		{
+			name: "vendor sources",
+			yaml: `
+source: proto/old
+target: proto/new
+vendor:
+  sources:
+    - prefix: acme/common
+      git: https://github.com/acme/protos.git
+      ref: v1.2.0
+      subdir: proto
+    - prefix: google
+      url: https://example.com/googleapis/{ref}/{path}
+      ref: 3f1c7b2
+`,
+		},
+		{
+			name: "vendor git source without ref",
+			yaml: `
+source: proto/old
+target: proto/new
+vendor:
+  sources:
+    - prefix: acme
+      git: https://github.com/acme/protos.git
+`,
+			wantErr: true,
+		},
+		{
+			name: "vendor url source without ref",
+			yaml: `
+source: proto/old
+target: proto/new
+vendor:
+  sources:
+    - prefix: google
+      url: https://example.com/googleapis/{ref}/{path}
+`,
+			wantErr: true,
+		},
+		{
+			name: "vendor source with two locations",
+			yaml: `
+source: proto/old
+target: proto/new
+vendor:
+  sources:
+    - prefix: acme
+      dir: third_party/acme
+      url: https://example.com/{path}
 `,
 			wantErr: true,
 		},
@@ -95,3 +174,39 @@ func TestRuleValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestParseBufTarget(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "buf.yaml"), []byte("version: v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		buf        string
+		wantTarget string
+		wantErr    bool
+	}{
+		{name: "explicit target", buf: "target: new", wantTarget: filepath.Join(dir, "new")},
+		{name: "in place", buf: "in_place: true", wantTarget: dir},
+		{name: "no target", buf: "update_config: true", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parse(strings.NewReader("buf:\n  path: " + dir + "\n  " + tt.buf + "\n"))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parse() target = %q, want an error", cfg.Target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse() error = %v", err)
+			}
+			if filepath.Clean(cfg.Target) != filepath.Clean(tt.wantTarget) {
+				t.Errorf("Target = %q, want %q", cfg.Target, tt.wantTarget)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package descriptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/reporter"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Compile compiles files, keyed by import path, into a FileDescriptorSet.
+// Imports not in files are searched below includePaths and among the
+// well-known types. Like protoc --include_imports, the set holds every
+// dependency, ordered so that each file follows its imports.
+func Compile(ctx context.Context, files map[string]string, includePaths []string) (*descriptorpb.FileDescriptorSet, error) {
+	compiler := newCompiler(func(name string) (io.Reader, error) {
+		if content, ok := files[name]; ok {
+			return strings.NewReader(content), nil
+		}
+		for _, root := range includePaths {
+			file, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+			if err == nil {
+				return file, nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}, nil)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	compiled, err := compiler.Compile(ctx, names...)
+	if err != nil {
+		return nil, fmt.Errorf("compiling descriptor set: %w", err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, fd := range compiled {
+		add(fd)
+	}
+	return set, nil
+}
+
+// Check compiles the named files, reading them and their imports with open,
+// and returns every error found rather than only the first. The well-known
+// types are always available.
+func Check(ctx context.Context, names []string, open func(name string) (string, bool)) ([]reporter.ErrorWithPos, error) {
+	var errs []reporter.ErrorWithPos
+	report := reporter.NewReporter(func(err reporter.ErrorWithPos) error {
+		errs = append(errs, err)
+		return nil
+	}, nil)
+	compiler := newCompiler(func(name string) (io.Reader, error) {
+		if content, ok := open(name); ok {
+			return strings.NewReader(content), nil
+		}
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}, report)
+
+	if _, err := compiler.Compile(ctx, names...); err != nil && !errors.Is(err, reporter.ErrInvalidSource) {
+		return errs, err
+	}
+	return errs, nil
+}
+
+// IsStandardImport reports whether name is one of the well-known types that
+// are always available.
+func IsStandardImport(name string) bool {
+	_, err := standardImports.FindFileByPath(name)
+	return err == nil
+}
+
+var standardImports = protocompile.WithStandardImports(protocompile.ResolverFunc(func(name string) (protocompile.SearchResult, error) {
+	return protocompile.SearchResult{}, fs.ErrNotExist
+}))
+
+func newCompiler(open func(name string) (io.Reader, error), report reporter.Reporter) *protocompile.Compiler {
+	resolver := protocompile.ResolverFunc(func(name string) (protocompile.SearchResult, error) {
+		source, err := open(name)
+		if err != nil {
+			return protocompile.SearchResult{}, err
+		}
+		return protocompile.SearchResult{Source: source}, nil
+	})
+	return &protocompile.Compiler{
+		Resolver:       protocompile.WithStandardImports(resolver),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+		Reporter:       report,
+	}
+}
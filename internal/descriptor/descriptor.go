@@ -0,0 +1,175 @@
+package descriptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// IsSetPath reports whether path names a FileDescriptorSet file rather than
+// a directory of .proto sources.
+func IsSetPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".binpb", ".pb", ".desc", ".protoset", ".json":
+		return true
+	}
+	return false
+}
+
+// ReadSet reads a binary or JSON encoded FileDescriptorSet.
+func ReadSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	set, _, err := readImage(path)
+	return set, err
+}
+
+// bufExtensionField is the field of buf.alpha.image.v1.ImageFile, a
+// FileDescriptorProto with extra fields, that holds buf's metadata about the
+// file; its field isImportField is set on files the module only imports.
+const (
+	bufExtensionField = 8042
+	isImportField     = 1
+)
+
+// readImage reads a FileDescriptorSet or buf image at path, along with the
+// names of the files a buf image marks as imports.
+func readImage(path string) (*descriptorpb.FileDescriptorSet, map[string]bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading descriptor set: %w", err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	imports := make(map[string]bool)
+	if isJSON(path, content) {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(content, set)
+		if err == nil {
+			err = jsonImports(content, imports)
+		}
+	} else {
+		err = proto.Unmarshal(content, set)
+		for _, fdp := range set.GetFile() {
+			if isImport(fdp.ProtoReflect().GetUnknown()) {
+				imports[fdp.GetName()] = true
+			}
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding descriptor set %s: %w", path, err)
+	}
+	return set, imports, nil
+}
+
+// isImport reports whether the unknown fields of a file descriptor hold buf
+// metadata marking it as an import.
+func isImport(unknown protoreflect.RawFields) bool {
+	ext, ok := bytesField(unknown, bufExtensionField)
+	if !ok {
+		return false
+	}
+	for len(ext) > 0 {
+		num, typ, n := protowire.ConsumeTag(ext)
+		if n < 0 {
+			return false
+		}
+		ext = ext[n:]
+		if num == isImportField && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(ext)
+			return n >= 0 && v != 0
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, ext); n < 0 {
+			return false
+		}
+		ext = ext[n:]
+	}
+	return false
+}
+
+// bytesField returns the last length-delimited field numbered num in b.
+func bytesField(b []byte, num protowire.Number) ([]byte, bool) {
+	var value []byte
+	var found bool
+	for len(b) > 0 {
+		n, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return nil, false
+		}
+		b = b[l:]
+		if n == num && typ == protowire.BytesType {
+			v, l := protowire.ConsumeBytes(b)
+			if l < 0 {
+				return nil, false
+			}
+			value, found = v, true
+			b = b[l:]
+			continue
+		}
+		if l = protowire.ConsumeFieldValue(n, typ, b); l < 0 {
+			return nil, false
+		}
+		b = b[l:]
+	}
+	return value, found
+}
+
+// jsonImports records the files of a JSON buf image marked as imports.
+func jsonImports(content []byte, imports map[string]bool) error {
+	var image struct {
+		File []struct {
+			Name         string `json:"name"`
+			BufExtension struct {
+				IsImport bool `json:"isImport"`
+			} `json:"bufExtension"`
+		} `json:"file"`
+	}
+	if err := json.Unmarshal(content, &image); err != nil {
+		return err
+	}
+	for _, f := range image.File {
+		if f.BufExtension.IsImport {
+			imports[f.Name] = true
+		}
+	}
+	return nil
+}
+
+// WriteSet writes set to path, as JSON when path ends in .json and in the
+// binary wire format otherwise.
+func WriteSet(path string, set *descriptorpb.FileDescriptorSet) error {
+	var content []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		content, err = protojson.MarshalOptions{Multiline: true}.Marshal(set)
+	} else {
+		content, err = proto.MarshalOptions{Deterministic: true}.Marshal(set)
+	}
+	if err != nil {
+		return fmt.Errorf("encoding descriptor set: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("writing descriptor set: %w", err)
+	}
+	return nil
+}
+
+func isJSON(path string, content []byte) bool {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return true
+	}
+	trimmed := bytes.TrimSpace(content)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
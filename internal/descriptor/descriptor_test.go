@@ -0,0 +1,181 @@
+package descriptor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRoundTrip(t *testing.T) {
+	files := map[string]string{
+		"acme/v1/types.proto": `syntax = "proto3";
+package acme.v1;
+message Status {
+  int32 code = 1;
+}`,
+		"acme/v1/service.proto": `syntax = "proto3";
+package acme.v1;
+import "acme/v1/types.proto";
+import "google/protobuf/timestamp.proto";
+message Item {
+  Status status = 1;
+  google.protobuf.Timestamp created_at = 2;
+}
+service Items {
+  rpc Get(Item) returns (Item);
+}`,
+	}
+
+	set, err := Compile(context.Background(), files, nil)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	var names []string
+	for _, f := range set.GetFile() {
+		names = append(names, f.GetName())
+	}
+	want := "acme/v1/types.proto google/protobuf/timestamp.proto acme/v1/service.proto"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("set files = %q, want %q (imports first)", got, want)
+	}
+
+	for _, ext := range []string{".binpb", ".json"} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schema"+ext)
+			if err := WriteSet(path, set); err != nil {
+				t.Fatalf("WriteSet() error = %v", err)
+			}
+
+			source := NewSource(path)
+			paths, err := source.Files(path)
+			if err != nil {
+				t.Fatalf("Files() error = %v", err)
+			}
+			wantPaths := []string{
+				filepath.Join(path, "acme", "v1", "service.proto"),
+				filepath.Join(path, "acme", "v1", "types.proto"),
+			}
+			if strings.Join(paths, " ") != strings.Join(wantPaths, " ") {
+				t.Fatalf("Files() = %v, want %v", paths, wantPaths)
+			}
+
+			content, err := source.ReadFile(paths[0])
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			for _, want := range []string{
+				`package acme.v1;`,
+				`import "acme/v1/types.proto";`,
+				`Status status = 1;`,
+				`service Items {`,
+			} {
+				if !strings.Contains(string(content), want) {
+					t.Errorf("printed file missing %q:\n%s", want, content)
+				}
+			}
+		})
+	}
+}
+
+func TestSourceSkipsImageImports(t *testing.T) {
+	set, err := Compile(context.Background(), map[string]string{
+		"acme/v1/item.proto": "syntax = \"proto3\";\npackage acme.v1;\nimport \"third/money.proto\";\nmessage Item { third.Money price = 1; }\n",
+		"third/money.proto":  "syntax = \"proto3\";\npackage third;\nmessage Money {}\n",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	var ext []byte
+	ext = protowire.AppendTag(ext, isImportField, protowire.VarintType)
+	ext = protowire.AppendVarint(ext, 1)
+	var unknown []byte
+	unknown = protowire.AppendTag(unknown, bufExtensionField, protowire.BytesType)
+	unknown = protowire.AppendBytes(unknown, ext)
+	for _, fdp := range set.GetFile() {
+		if fdp.GetName() == "third/money.proto" {
+			fdp.ProtoReflect().SetUnknown(unknown)
+		}
+	}
+
+	binary, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var image map[string]any
+	if err := json.Unmarshal([]byte(protojson.Format(set)), &image); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range image["file"].([]any) {
+		if f := f.(map[string]any); f["name"] == "third/money.proto" {
+			f["bufExtension"] = map[string]any{"isImport": true}
+		}
+	}
+	jsonImage, err := json.Marshal(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "image.binpb", content: binary},
+		{name: "image.json", content: jsonImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, tt.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			paths, err := NewSource(path).Files(path)
+			if err != nil {
+				t.Fatalf("Files() error = %v", err)
+			}
+			want := []string{filepath.Join(path, "acme", "v1", "item.proto")}
+			if strings.Join(paths, " ") != strings.Join(want, " ") {
+				t.Errorf("Files() = %v, want %v", paths, want)
+			}
+
+			imports, err := Imports(path)
+			if err != nil {
+				t.Fatalf("Imports() error = %v", err)
+			}
+			if len(imports) != 1 || !strings.Contains(imports["third/money.proto"], "message Money") {
+				t.Errorf("Imports() = %v, want third/money.proto", imports)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	_, err := Compile(context.Background(), map[string]string{
+		"a.proto": "syntax = \"proto3\";\nmessage A { Missing m = 1; }\n",
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "Missing") {
+		t.Errorf("Compile() error = %v, want undefined type", err)
+	}
+}
+
+func TestIsSetPath(t *testing.T) {
+	tests := map[string]bool{
+		"schema.binpb":  true,
+		"image.json":    true,
+		"out.pb":        true,
+		"proto/old":     false,
+		"proto/a.proto": false,
+	}
+	for path, want := range tests {
+		if got := IsSetPath(path); got != want {
+			t.Errorf("IsSetPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
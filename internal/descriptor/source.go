@@ -0,0 +1,81 @@
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackchuka/proto-migrate/internal/loader"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"google.golang.org/protobuf/reflect/protodesc"
+)
+
+// NewSource presents the files of the FileDescriptorSet at path as .proto
+// sources below path, so that a set can be migrated like a directory. Files
+// a buf image marks as imports and the well-known types are left out. The set
+// is read on first use.
+func NewSource(path string) *loader.MemorySource {
+	return loader.NewMemorySource(path, func() (map[string][]byte, error) {
+		sources, _, err := printSet(path)
+		return sources, err
+	})
+}
+
+// Imports prints the files of the descriptor set at path that NewSource
+// leaves out as imports, keyed by import path, so that the sources importing
+// them can still be checked.
+func Imports(path string) (map[string]string, error) {
+	_, imports, err := printSet(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(imports))
+	for name, content := range imports {
+		files[name] = string(content)
+	}
+	return files, nil
+}
+
+// printSet reads the descriptor set at path and prints every file in it,
+// split into sources and the files a buf image marks as imports. The
+// well-known types are left out. Imports missing from the set are tolerated
+// and printed by name.
+func printSet(path string) (sources, imports map[string][]byte, err error) {
+	set, isImport, err := readImage(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registry, err := protodesc.FileOptions{AllowUnresolvable: true}.NewFiles(set)
+	if err != nil {
+		return nil, nil, fmt.Errorf("linking descriptor set %s: %w", path, err)
+	}
+
+	sources, imports = make(map[string][]byte), make(map[string][]byte)
+	printer := &protoprint.Printer{}
+	for _, fdp := range set.GetFile() {
+		name := fdp.GetName()
+		if strings.HasPrefix(name, "google/protobuf/") {
+			continue
+		}
+
+		fd, err := registry.FindFileByPath(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("finding %s in descriptor set: %w", name, err)
+		}
+		wrapped, err := desc.WrapFile(fd)
+		if err != nil {
+			return nil, nil, fmt.Errorf("converting %s: %w", name, err)
+		}
+		content, err := printer.PrintProtoToString(wrapped)
+		if err != nil {
+			return nil, nil, fmt.Errorf("printing %s: %w", name, err)
+		}
+		if isImport[name] {
+			imports[name] = []byte(content)
+		} else {
+			sources[name] = []byte(content)
+		}
+	}
+	return sources, imports, nil
+}
@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jackchuka/proto-migrate/internal/loader"
+	"github.com/jackchuka/proto-migrate/internal/resolve"
+)
+
+// loadConsumers loads the protos of the consumer directories from the
+// working tree, honouring the excludes.
+func (e *Engine) loadConsumers() ([]*loader.ProtoFile, error) {
+	var files []*loader.ProtoFile
+	for _, dir := range e.config.Consumers {
+		l := loader.NewFromSource(loader.OSSource{Symlinks: loader.SymlinkPolicy(e.config.Symlinks)}, nil, e.config.Excludes)
+		loaded, err := l.LoadDirectory(dir)
+		if err != nil {
+			return nil, fmt.Errorf("loading consumer directory %s: %w", dir, err)
+		}
+		files = append(files, loaded...)
+	}
+	return files, nil
+}
+
+// setConsumers records the files of graph that are not source files as
+// consumers, which are rewritten in place.
+func (p *Plan) setConsumers(graph *resolve.Graph, files []*loader.ProtoFile) {
+	source := make(map[string]bool, len(files))
+	for _, file := range files {
+		source[filepath.Clean(file.Path)] = true
+	}
+
+	p.inPlace = make(map[string]bool)
+	for path, file := range graph.GetFiles() {
+		if !source[path] {
+			p.Consumers = append(p.Consumers, file)
+			p.inPlace[file.Path] = true
+		}
+	}
+	sort.Slice(p.Consumers, func(i, j int) bool {
+		return p.Consumers[i].Path < p.Consumers[j].Path
+	})
+}
+
+// displayPath returns path relative to the source directory, or as is for
+// a consumer.
+func (p *Plan) displayPath(path string) string {
+	if p.inPlace[path] {
+		return path
+	}
+	relPath, _ := filepath.Rel(p.SourceDir, path)
+	return relPath
+}
+
+// packageRenames returns the packages renamed by the package rules.
+func (e *Engine) packageRenames() map[string]string {
+	renames := make(map[string]string)
+	for _, rule := range e.config.Rules {
+		if rule.Kind == "package" {
+			renames[rule.From] = rule.To
+		}
+	}
+	return renames
+}
+
+// rewriteConsumers points the references of the consumers, including custom
+// option names, at the renamed packages. Their imports are rewritten along
+// with those of the source files.
+func (e *Engine) rewriteConsumers(plan *Plan) error {
+	renames := e.packageRenames()
+	if len(renames) == 0 {
+		return nil
+	}
+
+	refs := make(map[string][]resolve.Reference)
+	for _, ref := range plan.Graph.AllReferences() {
+		refs[ref.File] = append(refs[ref.File], ref)
+	}
+
+	for _, file := range plan.Consumers {
+		lines := strings.Split(file.Content, "\n")
+		edits := 0
+		// Going backwards keeps the columns of earlier references on a line
+		// valid when a name changes length.
+		fileRefs := refs[filepath.Clean(file.Path)]
+		for i := len(fileRefs) - 1; i >= 0; i-- {
+			ref := fileRefs[i]
+			if !ref.Resolved {
+				continue
+			}
+			renamed, ok := renamePackage(ref.Symbol, renames)
+			if !ok {
+				continue
+			}
+			name := "." + renamed
+			if ref.Name == ref.Symbol {
+				name = renamed
+			}
+			if replaceAt(lines, ref.Line, ref.Column, ref.Name, name) {
+				edits++
+			}
+		}
+
+		if edits > 0 {
+			file.Content = strings.Join(lines, "\n")
+			plan.Changes = append(plan.Changes, Change{
+				File:        file.Path,
+				Type:        "consumer",
+				Description: fmt.Sprintf("Updated %d references to renamed packages", edits),
+			})
+		}
+	}
+	return nil
+}
+
+// renamePackage maps a fully-qualified name into its renamed package, using
+// the longest renamed package containing it.
+func renamePackage(name string, renames map[string]string) (string, bool) {
+	longest := ""
+	for from := range renames {
+		if strings.HasPrefix(name, from+".") && len(from) > len(longest) {
+			longest = from
+		}
+	}
+	if longest == "" {
+		return "", false
+	}
+	return renames[longest] + strings.TrimPrefix(name, longest), true
+}
+
+// replaceAt replaces the first whole occurrence of old at or after the
+// 1-based line and column, before the end of the statement there. The search
+// continues on later lines, since the position of an rpc is that of its
+// keyword and its response type may follow on another line.
+func replaceAt(lines []string, line, column int, old, new string) bool {
+	if line < 1 {
+		return false
+	}
+	for start := max(column-1, 0); line <= len(lines); line, start = line+1, 0 {
+		text := lines[line-1]
+		if start > len(text) {
+			continue
+		}
+		end := len(text)
+		if i := strings.IndexAny(text[start:], ";{}"); i >= 0 {
+			end = start + i
+		}
+		if i, ok := indexName(text[:end], old, start); ok {
+			lines[line-1] = text[:i] + new + text[i+len(old):]
+			return true
+		}
+		if end < len(text) {
+			return false
+		}
+	}
+	return false
+}
+
+// indexName returns the index of the first whole occurrence of name in text
+// at or after start.
+func indexName(text, name string, start int) (int, bool) {
+	for start <= len(text) {
+		i := strings.Index(text[start:], name)
+		if i < 0 {
+			return 0, false
+		}
+		i += start
+		end := i + len(name)
+		if (i == 0 || !isNameByte(text[i-1])) && (end == len(text) || !isNameByte(text[end])) {
+			return i, true
+		}
+		start = i + 1
+	}
+	return 0, false
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// applyConsumers writes the changed consumers back in place.
+func applyConsumers(plan *Plan) error {
+	for _, file := range plan.Consumers {
+		if file.Content == file.Original {
+			continue
+		}
+		if err := os.WriteFile(file.Path, []byte(file.Content), 0644); err != nil {
+			return fmt.Errorf("writing consumer %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CycleError reports import cycles in the migrated schema, which protoc
+// rejects.
+type CycleError struct {
+	Cycles [][]string
+}
+
+func (e *CycleError) Error() string {
+	var b strings.Builder
+	count := fmt.Sprintf("%d import cycles", len(e.Cycles))
+	if len(e.Cycles) == 1 {
+		count = "an import cycle"
+	}
+	fmt.Fprintf(&b, "migrated schema has %s (set allow_cycles or --allow-cycles to write it anyway):", count)
+	for _, cycle := range e.Cycles {
+		b.WriteString("\n  ")
+		b.WriteString(formatCycle(cycle))
+	}
+	return b.String()
+}
+
+// formatCycle renders a cycle as a chain of imports back to its first file.
+func formatCycle(cycle []string) string {
+	return strings.Join(append(cycle[:len(cycle):len(cycle)], cycle[0]), " -> ")
+}
+
+// checkCycles records the import cycles of the source and the migrated files
+// and fails on cycles in the latter unless they are allowed.
+func (e *Engine) checkCycles(plan *Plan) error {
+	plan.SourceCycles = plan.Graph.Cycles()
+
+	target, err := e.TargetGraph(plan)
+	if err != nil {
+		return err
+	}
+	plan.Cycles = target.Cycles()
+
+	if len(plan.Cycles) > 0 && !e.config.AllowCycles && !e.flags.AllowCycles {
+		return &CycleError{Cycles: plan.Cycles}
+	}
+	return nil
+}
+
+func printCycles(title string, cycles [][]string) {
+	if len(cycles) == 0 {
+		return
+	}
+	fmt.Println(title)
+	for _, cycle := range cycles {
+		fmt.Printf("  ↻ %s\n", formatCycle(cycle))
+	}
+}
@@ -1,22 +1,32 @@
 package engine
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"unicode"
 
+	"github.com/emicklei/proto"
 	"github.com/fatih/color"
+	"github.com/jackchuka/proto-migrate/internal/archive"
+	"github.com/jackchuka/proto-migrate/internal/buf"
+	"github.com/jackchuka/proto-migrate/internal/compat"
 	"github.com/jackchuka/proto-migrate/internal/config"
+	"github.com/jackchuka/proto-migrate/internal/descriptor"
 	"github.com/jackchuka/proto-migrate/internal/loader"
 	"github.com/jackchuka/proto-migrate/internal/resolve"
 	"github.com/jackchuka/proto-migrate/internal/transform"
 	"github.com/jackchuka/proto-migrate/internal/types"
+	"github.com/jackchuka/proto-migrate/internal/validate"
 	"github.com/jackchuka/proto-migrate/internal/vendor"
 )
 
@@ -32,39 +42,147 @@ func New(cfg *config.Config, flags *types.GlobalFlags) *Engine {
 		_ = runtime.NumCPU()
 	}
 
+	var source loader.Source = loader.OSSource{Symlinks: loader.SymlinkPolicy(cfg.Symlinks)}
+	if rev := sourceRev(cfg, flags); rev != "" {
+		source = loader.NewGitSource(rev)
+	} else if descriptor.IsSetPath(cfg.Source) {
+		source = descriptor.NewSource(cfg.Source)
+	} else if archive.IsArchivePath(cfg.Source) {
+		source = archive.NewSource(cfg.Source)
+	}
+
 	return &Engine{
 		config: cfg,
 		flags:  flags,
-		loader: loader.New(cfg.Excludes),
+		loader: loader.NewFromSource(source, cfg.Includes, cfg.Excludes),
 	}
 }
 
-func (e *Engine) Plan(ctx context.Context) (*Plan, error) {
+// sourceRev returns the git revision to read the source from; --source-rev
+// overrides a git:// source in the config.
+func sourceRev(cfg *config.Config, flags *types.GlobalFlags) string {
+	if flags.SourceRev != "" {
+		return flags.SourceRev
+	}
+	return cfg.SourceRev
+}
+
+// includePaths returns the import roots: --proto-path flags first, then the
+// config's include_paths. Files in a descriptor set or archive are named by
+// import path, so such a source and its target are roots as well. Without
+// any configured root, imports are taken to be relative to the directory
+// holding the source, as in oldpackage/v1/a.proto for source oldpackage.
+func (e *Engine) includePaths() []string {
+	paths := make([]string, 0, len(e.flags.ProtoPath)+len(e.config.IncludePaths)+2)
+	paths = append(paths, e.flags.ProtoPath...)
+	paths = append(paths, e.config.IncludePaths...)
+	if descriptor.IsSetPath(e.config.Source) || archive.IsArchivePath(e.config.Source) || archive.IsArchivePath(e.config.Target) {
+		paths = append(paths, e.config.Target, e.config.Source)
+	} else if len(paths) == 0 && e.config.Source != "" {
+		paths = append(paths, filepath.Dir(filepath.Clean(e.config.Source)))
+	}
+	return paths
+}
+
+// foldsCase reports whether paths differing only in case collide in the
+// target: archives may be extracted anywhere, macOS and Windows fold case by
+// default, and elsewhere the filesystem holding the target is probed.
+func (e *Engine) foldsCase() bool {
+	if archive.IsArchivePath(e.config.Target) {
+		return true
+	}
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return true
+	}
+
+	dir, err := filepath.Abs(e.config.Target)
+	if err != nil {
+		return false
+	}
+	for ; ; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(dir); err == nil {
+			base := filepath.Base(dir)
+			if swapped := swapCase(base); swapped != base {
+				other, err := os.Stat(filepath.Join(filepath.Dir(dir), swapped))
+				return err == nil && os.SameFile(info, other)
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			return false
+		}
+	}
+}
+
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// Graph loads the source files and the consumers and resolves their
+// imports. Only the source files are returned.
+func (e *Engine) Graph(ctx context.Context) (*resolve.Graph, []*loader.ProtoFile, error) {
 	files, err := e.loader.LoadDirectory(e.config.Source)
 	if err != nil {
-		return nil, fmt.Errorf("loading source directory: %w", err)
+		return nil, nil, fmt.Errorf("loading source directory: %w", err)
+	}
+	if conflicts := e.loader.CaseConflicts(); len(conflicts) > 0 && e.foldsCase() {
+		return nil, nil, fmt.Errorf("loading source directory: %w", &loader.Error{Errors: conflicts})
+	}
+	consumers, err := e.loadConsumers()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	graph := resolve.NewGraph()
 	for _, file := range files {
 		graph.AddFile(file)
 	}
+	for _, file := range consumers {
+		graph.AddFile(file)
+	}
 
-	if err := graph.ResolveImports(e.config.Source); err != nil {
-		return nil, fmt.Errorf("resolving imports: %w", err)
+	if err := graph.ResolveImports(e.config.Source, e.includePaths()); err != nil {
+		return nil, nil, fmt.Errorf("resolving imports: %w", err)
+	}
+	return graph, files, nil
+}
+
+func (e *Engine) Plan(ctx context.Context) (*Plan, error) {
+	graph, files, err := e.Graph(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	plan := &Plan{
 		Changes:   make([]Change, 0),
 		SourceDir: e.config.Source,
 		TargetDir: e.config.Target,
+		SourceRev: sourceRev(e.config, e.flags),
 		Files:     files,
+		Skipped:   e.loader.Skipped(),
 		Graph:     graph,
+
+		relocations: e.relocations(),
+		roots:       e.importRoots(),
+	}
+	plan.setConsumers(graph, files)
+	if ws := e.config.Workspace(); ws != nil {
+		plan.BufDeps = ws.Deps
+	}
+
+	usage := newRuleUsage()
+	for _, file := range files {
+		usage.originals[file.Path] = file.Content
 	}
 
 	// Apply user-defined rules first
 	var appliedRules []transform.Rule
-	for _, ruleConfig := range e.config.Rules {
+	for i, ruleConfig := range e.config.Rules {
 		rule, err := transform.CreateRule(ruleConfig)
 		if err != nil {
 			return nil, fmt.Errorf("creating rule: %w", err)
@@ -72,11 +190,13 @@ func (e *Engine) Plan(ctx context.Context) (*Plan, error) {
 		appliedRules = append(appliedRules, rule)
 
 		for _, file := range files {
+			before := file.Content
 			changed, err := rule.Apply(file)
 			if err != nil {
 				return nil, fmt.Errorf("applying rule %s to %s: %w", rule.ID(), file.Path, err)
 			}
 			if changed {
+				usage.record(i, file.Path, before, file.Content)
 				plan.Changes = append(plan.Changes, Change{
 					File:        file.Path,
 					Type:        "transform",
@@ -87,9 +207,16 @@ func (e *Engine) Plan(ctx context.Context) (*Plan, error) {
 	}
 
 	// Generate and apply automatic import rules
-	autoImportRules := transform.GenerateAutoImportRules(e.config, appliedRules)
+	updates, skippedImports, err := importUpdates(plan)
+	if err != nil {
+		return nil, err
+	}
+	autoImportRules := transform.GenerateAutoImportRules(e.config, updates)
+	if err := e.rewriteConsumers(plan); err != nil {
+		return nil, err
+	}
 	for _, rule := range autoImportRules {
-		for _, file := range files {
+		for _, file := range append(files[:len(files):len(files)], plan.Consumers...) {
 			changed, err := rule.Apply(file)
 			if err != nil {
 				return nil, fmt.Errorf("applying auto-import rule %s to %s: %w", rule.ID(), file.Path, err)
@@ -104,15 +231,199 @@ func (e *Engine) Plan(ctx context.Context) (*Plan, error) {
 		}
 	}
 
+	if err := e.recordRelocations(plan, usage); err != nil {
+		return nil, err
+	}
+
+	// Group changes by file; within a file they stay in rule order.
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].File < plan.Changes[j].File
+	})
+
+	plan.Warnings = usage.warnings(e.config.Rules, appliedRules)
+	for _, skipped := range skippedImports {
+		plan.Warnings = append(plan.Warnings, Warning{
+			Rule:    autoImportRules[0].ID(),
+			Message: skipped,
+		})
+	}
+	deps, err := e.vendoredFiles()
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := e.validate(plan, deps)
+	if err != nil {
+		return nil, withWarnings(err, plan.Warnings)
+	}
+	plan.Warnings = append(plan.Warnings, warnings...)
+	for _, conflict := range e.loader.CaseConflicts() {
+		plan.Warnings = append(plan.Warnings, Warning{Rule: "load", Message: conflict.Error()})
+	}
+	if e.flags.Strict && len(plan.Warnings) > 0 {
+		return nil, strictError(plan.Warnings)
+	}
+	if err := e.checkCycles(plan); err != nil {
+		return nil, err
+	}
+
 	return plan, nil
 }
 
+// withWarnings adds the warnings collected so far to err, since they often
+// explain it, e.g. an import that was left unchanged.
+func withWarnings(err error, warnings []Warning) error {
+	if len(warnings) == 0 {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("\nwarnings:")
+	for _, w := range warnings {
+		b.WriteString("\n  ")
+		b.WriteString(w.String())
+	}
+	return fmt.Errorf("%w%s", err, b.String())
+}
+
+// strictError fails a plan with warnings of any kind in strict mode.
+func strictError(warnings []Warning) error {
+	var b strings.Builder
+	count := fmt.Sprintf("%d warnings", len(warnings))
+	if len(warnings) == 1 {
+		count = "a warning"
+	}
+	fmt.Fprintf(&b, "plan has %s in strict mode:", count)
+	for _, w := range warnings {
+		b.WriteString("\n  ")
+		b.WriteString(w.String())
+	}
+	return errors.New(b.String())
+}
+
+// WriteDescriptorSet compiles the migrated files, named by their import path
+// below the include paths or else relative to the target directory, and
+// writes them with all their imports as a FileDescriptorSet.
+func (e *Engine) WriteDescriptorSet(ctx context.Context, plan *Plan, path string) error {
+	includePaths := e.includePaths()
+	roots := append(includePaths[:len(includePaths):len(includePaths)], plan.TargetDir)
+	files := make(map[string]string, len(plan.Files))
+	for _, file := range plan.Files {
+		targetPath, err := plan.TargetPath(file)
+		if err != nil {
+			return err
+		}
+		files[importName(targetPath, roots)] = file.Content
+	}
+
+	set, err := descriptor.Compile(ctx, files, includePaths)
+	if err != nil {
+		return err
+	}
+	return descriptor.WriteSet(path, set)
+}
+
+// importName returns path relative to the first root containing it.
+func importName(path string, roots []string) string {
+	if rooted, ok := importRoot(path, roots); ok {
+		return rooted.name
+	}
+	return filepath.ToSlash(path)
+}
+
+// validate compiles the migrated files against each other, deps (the
+// vendored protos), the imports of a descriptor set source and the
+// well-known types so that a broken schema is never written. Files whose imports cannot be resolved yet are returned as
+// warnings.
+func (e *Engine) validate(plan *Plan, deps map[string]string) ([]Warning, error) {
+	files := make([]validate.File, 0, len(plan.Files)+len(plan.Consumers))
+	for _, file := range append(plan.Files[:len(plan.Files):len(plan.Files)], plan.Consumers...) {
+		targetPath, err := plan.TargetPath(file)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, validate.File{
+			Path:            file.Path,
+			OutputPath:      targetPath,
+			Content:         file.Content,
+			OriginalPackage: packageName(file.Proto),
+		})
+	}
+
+	for importPath, path := range plan.Graph.GetSiblingImports() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading sibling import %s: %w", importPath, err)
+		}
+		deps[importPath] = string(content)
+	}
+	if descriptor.IsSetPath(e.config.Source) {
+		imports, err := descriptor.Imports(e.config.Source)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(deps, imports)
+	}
+
+	var warnings []Warning
+	var errs []validate.Diagnostic
+	for _, diag := range validate.Validate(files, deps, e.includePaths()) {
+		if diag.Warning {
+			warnings = append(warnings, Warning{Rule: "validate", Message: diag.String()})
+		} else {
+			errs = append(errs, diag)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &validate.Error{Diagnostics: errs}
+	}
+	return warnings, nil
+}
+
+// revalidate checks the plan again once the protos vendored into vendorDir
+// are available, so that files importing them are checked before writing.
+func (e *Engine) revalidate(plan *Plan, vendorDir string) error {
+	deps, err := vendor.New(vendorDir, config.Vendor{}).VendoredFiles()
+	if err != nil {
+		return err
+	}
+	_, err = e.validate(plan, deps)
+	return err
+}
+
+func packageName(definition *proto.Proto) string {
+	var name string
+	proto.Walk(definition, proto.WithPackage(func(p *proto.Package) {
+		name = p.Name
+	}))
+	return name
+}
+
+// vendoredFiles returns the protos vendored into the target directory or
+// archive, keyed by import path. They must match vendor.lock.
+func (e *Engine) vendoredFiles() (map[string]string, error) {
+	if !archive.IsArchivePath(e.config.Target) {
+		return vendor.New(e.config.Target, config.Vendor{}).VendoredFiles()
+	}
+
+	files, err := archive.Read(e.config.Target)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return vendor.ArchiveFiles(files)
+}
+
 func (e *Engine) Apply(ctx context.Context, plan *Plan) error {
+	if archive.IsArchivePath(plan.TargetDir) {
+		return e.applyArchive(plan)
+	}
+
 	if e.flags.VendorDeps {
-		v := vendor.New(e.config.Target)
+		v := vendor.New(e.config.Target, e.config.Vendor)
 		if err := v.VendorExternalDeps(plan.Graph); err != nil {
 			return fmt.Errorf("vendoring dependencies: %w", err)
 		}
+		if err := e.revalidate(plan, e.config.Target); err != nil {
+			return err
+		}
 	}
 
 	tmpDir, err := os.MkdirTemp("", "proto-migrate-")
@@ -150,7 +461,10 @@ func (e *Engine) Apply(ctx context.Context, plan *Plan) error {
 		}
 
 		tmpPath := filepath.Join(tmpDir, relPath)
-		targetPath := filepath.Join(plan.TargetDir, relPath)
+		targetPath, err := plan.TargetPath(file)
+		if err != nil {
+			return err
+		}
 
 		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 			return fmt.Errorf("creating target directory: %w", err)
@@ -164,15 +478,182 @@ func (e *Engine) Apply(ctx context.Context, plan *Plan) error {
 		}
 	}
 
+	if err := applyConsumers(plan); err != nil {
+		return err
+	}
+
+	if ws := e.config.Workspace(); ws != nil && e.config.Buf.UpdateConfig {
+		if err := buf.UpdateConfig(ws, plan.SourceDir, plan.TargetDir, e.config.Buf.Name); err != nil {
+			return fmt.Errorf("updating buf config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// applyArchive stages the migrated tree, along with the vendored protos of an
+// existing target archive, and packs it into a new archive at the target.
+func (e *Engine) applyArchive(plan *Plan) error {
+	tmpDir, err := os.MkdirTemp("", "proto-migrate-")
+	if err != nil {
+		return fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	existing, err := archive.Read(plan.TargetDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for name, content := range existing {
+		if strings.HasPrefix(name, "vendor/") {
+			if err := writeFile(filepath.Join(tmpDir, filepath.FromSlash(name)), content); err != nil {
+				return err
+			}
+		}
+	}
+
+	if e.flags.VendorDeps {
+		if err := vendor.New(tmpDir, e.config.Vendor).VendorExternalDeps(plan.Graph); err != nil {
+			return fmt.Errorf("vendoring dependencies: %w", err)
+		}
+		if err := e.revalidate(plan, tmpDir); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range plan.Files {
+		name, err := plan.archiveName(file)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(tmpDir, filepath.FromSlash(name)), []byte(file.Content)); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.WriteDir(plan.TargetDir, tmpDir); err != nil {
+		return err
+	}
+	return applyConsumers(plan)
+}
+
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("writing temp file: %w", err)
+	}
 	return nil
 }
 
 type Plan struct {
 	Changes   []Change
 	SourceDir string
+	SourceRev string
 	TargetDir string
 	Files     []*loader.ProtoFile
+	// Consumers are the files of the consumer directories, updated in
+	// place.
+	Consumers []*loader.ProtoFile
+	Skipped   []loader.Skip
 	Graph     *resolve.Graph
+	Warnings  []Warning
+	Compat    []compat.Issue
+	BufDeps   []string
+
+	// SourceCycles and Cycles are the import cycles before and after the
+	// migration, each listed as files in import order.
+	SourceCycles [][]string
+	Cycles       [][]string
+
+	// relocations and the import roots they are relative to decide where
+	// each file is written.
+	relocations *resolve.Relocations
+	roots       []string
+	inPlace     map[string]bool
+}
+
+// CheckCompat compares each file's original definitions with its migrated
+// content and records every wire or JSON incompatibility in p.Compat.
+func (p *Plan) CheckCompat() error {
+	files := make([]compat.File, 0, len(p.Files))
+	for _, file := range p.Files {
+		migrated, err := loader.Parse(file.Path, file.Content)
+		if err != nil {
+			return fmt.Errorf("parsing migrated %s: %w", file.Path, err)
+		}
+		files = append(files, compat.File{
+			Path:     file.Path,
+			Original: file.Proto,
+			Migrated: migrated,
+		})
+	}
+
+	p.Compat = compat.Check(files)
+	return nil
+}
+
+// TargetPath returns the location file is written to by Apply: its place
+// below the target directory, unless a relocation moves it elsewhere.
+func (p *Plan) TargetPath(file *loader.ProtoFile) (string, error) {
+	if p.inPlace[file.Path] {
+		return file.Path, nil
+	}
+	defaultPath, err := p.defaultTargetPath(file)
+	if err != nil {
+		return "", err
+	}
+	if relocated, ok := p.relocatedPath(file, defaultPath); ok {
+		return relocated, nil
+	}
+	return defaultPath, nil
+}
+
+func (p *Plan) defaultTargetPath(file *loader.ProtoFile) (string, error) {
+	relPath, err := filepath.Rel(p.SourceDir, file.Path)
+	if err != nil {
+		return "", fmt.Errorf("calculating relative path: %w", err)
+	}
+	return filepath.Join(p.TargetDir, relPath), nil
+}
+
+// ChangedFiles returns the paths of the files the plan changes.
+func (p *Plan) ChangedFiles() map[string]bool {
+	changed := make(map[string]bool, len(p.Changes))
+	for _, change := range p.Changes {
+		changed[change.File] = true
+	}
+	return changed
+}
+
+// TargetGraph returns the import graph of the migrated files and consumers,
+// keyed by the paths they are written to.
+func (e *Engine) TargetGraph(plan *Plan) (*resolve.Graph, error) {
+	graph := resolve.NewGraph()
+	for _, file := range append(plan.Files[:len(plan.Files):len(plan.Files)], plan.Consumers...) {
+		targetPath, err := plan.TargetPath(file)
+		if err != nil {
+			return nil, err
+		}
+		migrated, err := loader.Parse(targetPath, file.Content)
+		if err != nil {
+			return nil, fmt.Errorf("parsing migrated %s: %w", file.Path, err)
+		}
+		graph.AddFile(&loader.ProtoFile{
+			Path:     targetPath,
+			Proto:    migrated,
+			Content:  file.Content,
+			Original: file.Original,
+		})
+	}
+
+	if err := graph.ResolveImports(plan.TargetDir, e.includePaths()); err != nil {
+		return nil, fmt.Errorf("resolving imports: %w", err)
+	}
+	return graph, nil
 }
 
 type Change struct {
@@ -183,33 +664,113 @@ type Change struct {
 
 func (p *Plan) Print() error {
 	fmt.Printf("\nPlan Summary:\n")
-	fmt.Printf("  Source: %s\n", p.SourceDir)
+	if p.SourceRev != "" {
+		fmt.Printf("  Source: %s (at %s)\n", p.SourceDir, p.SourceRev)
+	} else {
+		fmt.Printf("  Source: %s\n", p.SourceDir)
+	}
 	fmt.Printf("  Target: %s\n", p.TargetDir)
 	fmt.Printf("  Files: %d\n", len(p.Files))
-	fmt.Printf("  Changes: %d\n\n", len(p.Changes))
+	if len(p.Consumers) > 0 {
+		fmt.Printf("  Consumer files: %d\n", len(p.Consumers))
+	}
+	if len(p.Skipped) > 0 {
+		fmt.Printf("  Skipped files: %d\n", len(p.Skipped))
+	}
+	fmt.Printf("  Changes: %d\n", len(p.Changes))
+	if len(p.BufDeps) > 0 {
+		fmt.Printf("  Buf dependencies: %s\n", strings.Join(p.BufDeps, ", "))
+	}
+	if p.Graph != nil {
+		fmt.Printf("  Sibling imports: %d\n", len(p.Graph.GetSiblingImports()))
+		fmt.Printf("  External imports: %d\n", len(p.Graph.GetExternalImports()))
+	}
+	fmt.Println()
 
 	if len(p.Changes) > 0 {
 		fmt.Println("Changes to be applied:")
 		for _, change := range p.Changes {
-			relPath, _ := filepath.Rel(p.SourceDir, change.File)
-			fmt.Printf("  • %s: %s\n", relPath, change.Description)
+			fmt.Printf("  • %s: %s\n", p.displayPath(change.File), change.Description)
 		}
 	}
 
+	if len(p.Skipped) > 0 {
+		fmt.Println("\nSkipped files:")
+		for _, skip := range p.Skipped {
+			relPath, _ := filepath.Rel(p.SourceDir, skip.Path)
+			if skip.Pattern == "" {
+				fmt.Printf("  - %s (matches no include pattern)\n", relPath)
+			} else {
+				fmt.Printf("  - %s (excluded by %q)\n", relPath, skip.Pattern)
+			}
+		}
+	}
+
+	if len(p.Warnings) > 0 {
+		fmt.Println("\nWarnings:")
+		for _, w := range p.Warnings {
+			fmt.Printf("  ! %s\n", w)
+		}
+	}
+
+	printCycles("\nImport cycles in source:", p.SourceCycles)
+	printCycles("\nImport cycles after migration:", p.Cycles)
+
+	p.printCompat(compat.SeverityWire, "Wire-breaking changes:")
+	p.printCompat(compat.SeverityJSON, "JSON/text-format changes:")
+
 	return nil
 }
 
+func (p *Plan) printCompat(severity compat.Severity, title string) {
+	printed := false
+	for _, issue := range p.Compat {
+		if issue.Severity != severity {
+			continue
+		}
+		if !printed {
+			fmt.Printf("\n%s\n", title)
+			printed = true
+		}
+		relPath, _ := filepath.Rel(p.SourceDir, issue.File)
+		fmt.Printf("  • %s:%d: %s: %s\n", relPath, issue.Line, issue.Element, issue.Message)
+	}
+}
+
 func (p *Plan) PrintJSON() error {
 	output := struct {
-		Source  string   `json:"source"`
-		Target  string   `json:"target"`
-		Files   int      `json:"files"`
-		Changes []Change `json:"changes"`
+		Source   string            `json:"source"`
+		Rev      string            `json:"source_rev,omitempty"`
+		Target   string            `json:"target"`
+		Files    int               `json:"files"`
+		Consumer int               `json:"consumer_files,omitempty"`
+		Skipped  []loader.Skip     `json:"skipped,omitempty"`
+		Changes  []Change          `json:"changes"`
+		BufDeps  []string          `json:"buf_deps,omitempty"`
+		Siblings map[string]string `json:"siblings,omitempty"`
+		External []string          `json:"external,omitempty"`
+		Warnings []Warning         `json:"warnings,omitempty"`
+		Compat   []compat.Issue    `json:"compat,omitempty"`
+		Cycles   [][]string        `json:"cycles,omitempty"`
+		Before   [][]string        `json:"source_cycles,omitempty"`
 	}{
-		Source:  p.SourceDir,
-		Target:  p.TargetDir,
-		Files:   len(p.Files),
-		Changes: p.Changes,
+		Source:   p.SourceDir,
+		Rev:      p.SourceRev,
+		Target:   p.TargetDir,
+		Files:    len(p.Files),
+		Consumer: len(p.Consumers),
+		Skipped:  p.Skipped,
+		Changes:  p.Changes,
+		Warnings: p.Warnings,
+		Compat:   p.Compat,
+		BufDeps:  p.BufDeps,
+		Cycles:   p.Cycles,
+		Before:   p.SourceCycles,
+	}
+	if p.Graph != nil {
+		output.Siblings = p.Graph.GetSiblingImports()
+		output.External = p.Graph.GetExternalImports()
+		sort.Strings(output.External)
 	}
 
 	encoder := json.NewEncoder(os.Stdout)
@@ -217,22 +778,81 @@ func (p *Plan) PrintJSON() error {
 	return encoder.Encode(output)
 }
 
+// Diff writes a unified diff of each file's migration against the source
+// content it was loaded from.
 func (p *Plan) Diff(w io.Writer) (bool, error) {
+	return p.diff(w, func(file *loader.ProtoFile) (string, error) {
+		return file.Original, nil
+	})
+}
+
+// DiffTarget writes a unified diff of what is currently in the target
+// directory against what the migration produces. Missing target files diff
+// as empty.
+func (p *Plan) DiffTarget(w io.Writer) (bool, error) {
+	if archive.IsArchivePath(p.TargetDir) {
+		existing, err := archive.Read(p.TargetDir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+		return p.diff(w, func(file *loader.ProtoFile) (string, error) {
+			if p.inPlace[file.Path] {
+				return readTarget(file.Path)
+			}
+			name, err := p.archiveName(file)
+			if err != nil {
+				return "", err
+			}
+			return string(existing[name]), nil
+		})
+	}
+
+	return p.diff(w, func(file *loader.ProtoFile) (string, error) {
+		targetPath, err := p.TargetPath(file)
+		if err != nil {
+			return "", err
+		}
+		return readTarget(targetPath)
+	})
+}
+
+// readTarget returns the content of a target file, or nothing if it does not
+// exist yet.
+func readTarget(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	return string(content), nil
+}
+
+// archiveName returns the entry file is written to in a target archive.
+func (p *Plan) archiveName(file *loader.ProtoFile) (string, error) {
+	targetPath, err := p.TargetPath(file)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := filepath.Rel(p.TargetDir, targetPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is relocated to %s, outside the target archive", file.Path, targetPath)
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+func (p *Plan) diff(w io.Writer, base func(*loader.ProtoFile) (string, error)) (bool, error) {
 	hasDiffs := false
 
-	for _, file := range p.Files {
-		originalPath := file.Path
-		originalContent, err := os.ReadFile(originalPath)
+	for _, file := range append(p.Files[:len(p.Files):len(p.Files)], p.Consumers...) {
+		original, err := base(file)
 		if err != nil {
-			originalContent = []byte{}
+			return hasDiffs, err
 		}
 
-		if !bytes.Equal(originalContent, []byte(file.Content)) {
+		if original != file.Content {
 			hasDiffs = true
-			relPath, _ := filepath.Rel(p.SourceDir, file.Path)
 
-			_, _ = color.New(color.Bold).Fprintf(w, "\n=== %s ===\n", relPath)
-			printUnifiedDiff(w, string(originalContent), file.Content)
+			_, _ = color.New(color.Bold).Fprintf(w, "\n=== %s ===\n", p.displayPath(file.Path))
+			printUnifiedDiff(w, original, file.Content)
 		}
 	}
 
@@ -0,0 +1,578 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jackchuka/proto-migrate/internal/archive"
+	"github.com/jackchuka/proto-migrate/internal/config"
+	"github.com/jackchuka/proto-migrate/internal/types"
+	"github.com/jackchuka/proto-migrate/internal/validate"
+)
+
+func TestPlanChangeOrder(t *testing.T) {
+	source := t.TempDir()
+	for i := range 50 {
+		content := fmt.Sprintf("syntax = \"proto3\";\npackage old.v1;\nservice S%d {}\n", i)
+		path := filepath.Join(source, fmt.Sprintf("dir%d", i%5), fmt.Sprintf("f%d.proto", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Source: source,
+		Target: t.TempDir(),
+		Rules: []config.Rule{
+			{Kind: "package", From: "old.v1", To: "new.v1"},
+			{Kind: "service", From: "S1", To: "T1"},
+		},
+	}
+
+	var first []Change
+	for run := range 3 {
+		plan, err := New(cfg, &types.GlobalFlags{}).Plan(context.Background())
+		if err != nil {
+			t.Fatalf("Plan() error = %v", err)
+		}
+		if !sort.SliceIsSorted(plan.Changes, func(i, j int) bool {
+			return plan.Changes[i].File < plan.Changes[j].File
+		}) {
+			t.Fatalf("run %d: changes not sorted by file", run)
+		}
+		if run == 0 {
+			first = plan.Changes
+		} else if !reflect.DeepEqual(plan.Changes, first) {
+			t.Fatalf("run %d: changes differ from first run", run)
+		}
+	}
+}
+
+func TestApplyArchive(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "api.zip")
+	target := filepath.Join(dir, "out", "api.tar.gz")
+	err := archive.Write(source, map[string][]byte{
+		"acme/v1/service.proto": []byte("syntax = \"proto3\";\npackage acme.v1;\nimport \"acme/v1/types.proto\";\nservice OldService {\n  rpc Get(Item) returns (Item);\n}\n"),
+		"acme/v1/types.proto":   []byte("syntax = \"proto3\";\npackage acme.v1;\nmessage Item {}\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Source: source,
+		Target: target,
+		Rules:  []config.Rule{{Kind: "service", From: "OldService", To: "NewService"}},
+	}
+	eng := New(cfg, &types.GlobalFlags{})
+	plan, err := eng.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if err := eng.Apply(context.Background(), plan); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	files, err := archive.Read(target)
+	if err != nil {
+		t.Fatalf("reading target archive: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("target archive has %d files, want 2", len(files))
+	}
+	if got := string(files["acme/v1/service.proto"]); !strings.Contains(got, "service NewService") {
+		t.Errorf("service.proto = %q, want renamed service", got)
+	}
+}
+
+func TestPlanImportCycles(t *testing.T) {
+	source := t.TempDir()
+	files := map[string]string{
+		"a/x.proto": "syntax = \"proto3\";\npackage old.v1;\nimport \"b/y.proto\";\nmessage X {}\n",
+		"b/y.proto": "syntax = \"proto3\";\npackage old.v1;\nimport \"a/x.proto\";\nmessage Y {}\n",
+		"c/z.proto": "syntax = \"proto3\";\npackage old.v1;\nimport \"a/x.proto\";\nmessage Z {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(source, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := t.TempDir()
+
+	tests := []struct {
+		name    string
+		allow   bool
+		wantErr bool
+	}{
+		{name: "cycles are fatal", wantErr: true},
+		{name: "cycles allowed", allow: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Source:       source,
+				Target:       target,
+				IncludePaths: []string{source},
+				AllowCycles:  tt.allow,
+				Rules:        []config.Rule{{Kind: "package", From: "old.v1", To: "new.v1"}},
+			}
+			plan, err := New(cfg, &types.GlobalFlags{}).Plan(context.Background())
+			if tt.wantErr {
+				var cycleErr *CycleError
+				if !errors.As(err, &cycleErr) || len(cycleErr.Cycles) != 1 {
+					t.Fatalf("Plan() error = %v, want one cycle", err)
+				}
+				if !strings.Contains(err.Error(), "has an import cycle") {
+					t.Errorf("Plan() error = %q, want it to count one import cycle", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+
+			wantSource := [][]string{{filepath.Join(source, "a", "x.proto"), filepath.Join(source, "b", "y.proto")}}
+			if !reflect.DeepEqual(plan.SourceCycles, wantSource) {
+				t.Errorf("SourceCycles = %v, want %v", plan.SourceCycles, wantSource)
+			}
+			want := [][]string{{filepath.Join(target, "a", "x.proto"), filepath.Join(target, "b", "y.proto")}}
+			if !reflect.DeepEqual(plan.Cycles, want) {
+				t.Errorf("Cycles = %v, want %v", plan.Cycles, want)
+			}
+		})
+	}
+}
+
+func TestPlanRelocate(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"old/v1/a.proto":          "syntax = \"proto3\";\npackage old.v1;\nimport \"old/v1/internal/b.proto\";\nmessage A { B b = 1; }\n",
+		"old/v1/internal/b.proto": "syntax = \"proto3\";\npackage old.v1;\nmessage B {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Source:       filepath.Join(root, "old"),
+		Target:       filepath.Join(root, "new"),
+		IncludePaths: []string{root},
+		Rules: []config.Rule{
+			{Kind: "package", From: "old.v1", To: "new.v1"},
+			{Kind: "relocate", From: "old/v1/internal", To: "new/v1/private"},
+		},
+	}
+	plan, err := New(cfg, &types.GlobalFlags{}).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, file := range plan.Files {
+		targetPath, err := plan.TargetPath(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(root, targetPath)
+		got[filepath.ToSlash(rel)] = file.Content
+	}
+
+	a, ok := got["new/v1/a.proto"]
+	if !ok || !strings.Contains(a, `import "new/v1/private/b.proto";`) {
+		t.Errorf("a.proto not rewritten to the relocated import: %v", got)
+	}
+	if _, ok := got["new/v1/private/b.proto"]; !ok {
+		t.Errorf("b.proto not relocated: %v", got)
+	}
+	if len(plan.Warnings) != 0 {
+		t.Errorf("Warnings = %v, want none", plan.Warnings)
+	}
+}
+
+func TestApplyArchiveRelocate(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "api.zip")
+	target := filepath.Join(dir, "out.zip")
+	err := archive.Write(source, map[string][]byte{
+		"acme/v1/a.proto":          []byte("syntax = \"proto3\";\npackage acme.v1;\nimport \"acme/v1/internal/b.proto\";\nmessage A { B b = 1; }\n"),
+		"acme/v1/internal/b.proto": []byte("syntax = \"proto3\";\npackage acme.v1;\nmessage B {}\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Source: source,
+		Target: target,
+		Rules:  []config.Rule{{Kind: "relocate", From: "acme/v1/internal", To: "acme/v1/private"}},
+	}
+	eng := New(cfg, &types.GlobalFlags{})
+	plan, err := eng.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	var diff strings.Builder
+	if _, err := plan.DiffTarget(&diff); err != nil {
+		t.Fatalf("DiffTarget() error = %v", err)
+	}
+	if err := eng.Apply(context.Background(), plan); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	files, err := archive.Read(target)
+	if err != nil {
+		t.Fatalf("reading target archive: %v", err)
+	}
+	if _, ok := files["acme/v1/private/b.proto"]; !ok || len(files) != 2 {
+		t.Errorf("target archive has %d files, want a.proto and the relocated b.proto", len(files))
+	}
+	if got := string(files["acme/v1/a.proto"]); !strings.Contains(got, `import "acme/v1/private/b.proto";`) {
+		t.Errorf("a.proto = %q, want the relocated import", got)
+	}
+}
+
+func TestPlanRelocateCollisions(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []config.Rule
+	}{
+		{
+			name: "two rules to one path",
+			rules: []config.Rule{
+				{Kind: "relocate", From: "old/v1/a", To: "new/v1/c"},
+				{Kind: "relocate", From: "old/v1/b", To: "new/v1/c"},
+			},
+		},
+		{
+			name:  "rule onto an unmoved file",
+			rules: []config.Rule{{Kind: "relocate", From: "old/v1/a", To: "new/v1/b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{
+				"old/v1/a/x.proto": "syntax = \"proto3\";\npackage v1;\nmessage A {}\n",
+				"old/v1/b/x.proto": "syntax = \"proto3\";\npackage v1;\nmessage B {}\n",
+			})
+			cfg := &config.Config{
+				Source:       filepath.Join(root, "old"),
+				Target:       filepath.Join(root, "new"),
+				IncludePaths: []string{root},
+				Rules:        tt.rules,
+			}
+			_, err := New(cfg, &types.GlobalFlags{}).Plan(context.Background())
+			if err == nil || !strings.Contains(err.Error(), "are both written to") {
+				t.Errorf("Plan() error = %v, want a collision", err)
+			}
+		})
+	}
+}
+
+func TestPlanImportRewrites(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"oldpackage/v1/types.proto":     "syntax = \"proto3\";\npackage oldpackage.v1;\nmessage T {}\n",
+		"oldpackage/v1/local.proto":     "syntax = \"proto3\";\npackage oldpackage.v1;\nmessage L {}\n",
+		"oldpackage/v1/service.proto":   "syntax = \"proto3\";\npackage oldpackage.v1;\nimport \"oldpackage/v1/types.proto\";\nimport public \"local.proto\";\nimport \"vendor/oldpackage/v1x/a.proto\";\nmessage S { T t = 1; L l = 2; vendor.A a = 3; }\n",
+		"vendor/oldpackage/v1x/a.proto": "syntax = \"proto3\";\npackage vendor;\nmessage A {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Source:       filepath.Join(root, "oldpackage"),
+		Target:       filepath.Join(root, "newpackage"),
+		IncludePaths: []string{root, filepath.Join(root, "oldpackage", "v1")},
+		Rules:        []config.Rule{{Kind: "package", From: "oldpackage.v1", To: "newpackage.v1"}},
+	}
+	plan, err := New(cfg, &types.GlobalFlags{}).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	var service string
+	for _, file := range plan.Files {
+		if filepath.Base(file.Path) == "service.proto" {
+			service = file.Content
+		}
+	}
+	for _, want := range []string{
+		`import "newpackage/v1/types.proto";`,
+		`import public "local.proto";`,
+		`import "vendor/oldpackage/v1x/a.proto";`,
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service.proto missing %s:\n%s", want, service)
+		}
+	}
+
+	var warned bool
+	for _, w := range plan.Warnings {
+		if strings.Contains(w.Message, `"vendor/oldpackage/v1x/a.proto"`) {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Errorf("Warnings = %v, want the unresolved lookalike import reported", plan.Warnings)
+	}
+}
+
+func TestPlanImportRewritesWithoutIncludePaths(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"oldpackage/v1/types.proto":   "syntax = \"proto3\";\npackage oldpackage.v1;\nmessage T {}\n",
+		"oldpackage/v1/service.proto": "syntax = \"proto3\";\npackage oldpackage.v1;\nimport \"oldpackage/v1/types.proto\";\nmessage S { T t = 1; }\n",
+	})
+
+	cfg := &config.Config{
+		Source: filepath.Join(root, "oldpackage"),
+		Target: filepath.Join(root, "newpackage"),
+		Rules:  []config.Rule{{Kind: "package", From: "oldpackage.v1", To: "newpackage.v1"}},
+	}
+	plan, err := New(cfg, &types.GlobalFlags{}).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	for _, file := range plan.Files {
+		if filepath.Base(file.Path) == "service.proto" && !strings.Contains(file.Content, `import "newpackage/v1/types.proto";`) {
+			t.Errorf("service.proto import not rewritten:\n%s", file.Content)
+		}
+	}
+}
+
+func TestPlanValidationErrorWarnings(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"old/v1/a.proto": "syntax = \"proto3\";\npackage old.v1;\nimport \"vendor/old/v1/x.proto\";\nmessage A {}\n",
+		"old/v1/b.proto": "syntax = \"proto3\";\npackage old.v1;\nmessage B { Missing m = 1; }\n",
+	})
+
+	cfg := &config.Config{
+		Source: filepath.Join(root, "old"),
+		Target: filepath.Join(root, "new"),
+		Rules:  []config.Rule{{Kind: "package", From: "old.v1", To: "new.v1"}},
+	}
+	_, err := New(cfg, &types.GlobalFlags{}).Plan(context.Background())
+	var validateErr *validate.Error
+	if !errors.As(err, &validateErr) {
+		t.Fatalf("Plan() error = %v, want a validation error", err)
+	}
+	if !strings.Contains(err.Error(), `import "vendor/old/v1/x.proto" in `) {
+		t.Errorf("Plan() error = %v, want the skipped import warning", err)
+	}
+}
+
+func TestApplyConsumers(t *testing.T) {
+	root := t.TempDir()
+	consumer := "syntax = \"proto3\";\npackage team.v1;\nimport \"old/v1/types.proto\";\noption (old.v1.owner) = \"team\";\nmessage Order {\n  old.v1.Item item = 1;\n  repeated .old.v1.Item items = 2 [(old.v1.sensitive) = true];\n}\n"
+	files := map[string]string{
+		"api/old/v1/types.proto": "syntax = \"proto3\";\npackage old.v1;\nimport \"google/protobuf/descriptor.proto\";\nextend google.protobuf.FileOptions { string owner = 50000; }\nextend google.protobuf.FieldOptions { bool sensitive = 50001; }\nmessage Item {}\n",
+		"team/v1/order.proto":    consumer,
+		"team/v1/other.proto":    "syntax = \"proto3\";\npackage team.v1;\nmessage Other {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Source:       filepath.Join(root, "api", "old"),
+		Target:       filepath.Join(root, "api", "new"),
+		IncludePaths: []string{filepath.Join(root, "api"), root},
+		Consumers:    []string{filepath.Join(root, "team")},
+		Rules:        []config.Rule{{Kind: "package", From: "old.v1", To: "new.v1"}},
+	}
+	eng := New(cfg, &types.GlobalFlags{})
+	plan, err := eng.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(plan.Consumers) != 2 {
+		t.Fatalf("Consumers = %d, want 2", len(plan.Consumers))
+	}
+	if err := eng.Apply(context.Background(), plan); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(root, "team", "v1", "order.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "syntax = \"proto3\";\npackage team.v1;\nimport \"new/v1/types.proto\";\noption (new.v1.owner) = \"team\";\nmessage Order {\n  new.v1.Item item = 1;\n  repeated .new.v1.Item items = 2 [(new.v1.sensitive) = true];\n}\n"
+	if string(got) != want {
+		t.Errorf("consumer =\n%s\nwant\n%s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(root, "api", "new", "team")); !os.IsNotExist(err) {
+		t.Error("consumer copied into the target")
+	}
+}
+
+func TestPlanConsumersMultiLineRPC(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"api/old/v1/types.proto": "syntax = \"proto3\";\npackage old.v1;\nmessage Item {}\nmessage Status {}\n",
+		"team/v1/billing.proto":  "syntax = \"proto3\";\npackage team.v1;\nimport \"old/v1/types.proto\";\nservice Billing {\n  rpc Charge(old.v1.Item)\n    returns (old.v1.Status);\n  rpc Refund(old.v1.Item) returns (old.v1.Status) {}\n}\n",
+	})
+
+	cfg := &config.Config{
+		Source:       filepath.Join(root, "api", "old"),
+		Target:       filepath.Join(root, "api", "new"),
+		IncludePaths: []string{filepath.Join(root, "api"), root},
+		Consumers:    []string{filepath.Join(root, "team")},
+		Rules:        []config.Rule{{Kind: "package", From: "old.v1", To: "new.v1"}},
+	}
+	plan, err := New(cfg, &types.GlobalFlags{}).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(plan.Consumers) != 1 {
+		t.Fatalf("Consumers = %d, want 1", len(plan.Consumers))
+	}
+	want := "syntax = \"proto3\";\npackage team.v1;\nimport \"new/v1/types.proto\";\nservice Billing {\n  rpc Charge(new.v1.Item)\n    returns (new.v1.Status);\n  rpc Refund(new.v1.Item) returns (new.v1.Status) {}\n}\n"
+	if got := plan.Consumers[0].Content; got != want {
+		t.Errorf("consumer =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestApplyRevalidatesVendoredImports(t *testing.T) {
+	source, target, shared := t.TempDir(), t.TempDir(), t.TempDir()
+	writeTree(t, source, map[string]string{
+		"item.proto": "syntax = \"proto3\";\npackage old.v1;\nimport \"acme/money.proto\";\nmessage Item { acme.Money price = 1; }\n",
+	})
+	writeTree(t, shared, map[string]string{
+		"acme/money.proto": "syntax = \"proto3\";\npackage acme;\nmessage Cash {}\n",
+	})
+
+	cfg := &config.Config{
+		Source: source,
+		Target: target,
+		Vendor: config.Vendor{Paths: []string{shared}},
+		Rules:  []config.Rule{{Kind: "package", From: "old.v1", To: "new.v1"}},
+	}
+	eng := New(cfg, &types.GlobalFlags{VendorDeps: true})
+	plan, err := eng.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0].Message, `import "acme/money.proto" was not found`) {
+		t.Errorf("Warnings = %v, want the unresolved import", plan.Warnings)
+	}
+
+	err = eng.Apply(context.Background(), plan)
+	var validateErr *validate.Error
+	if !errors.As(err, &validateErr) || !strings.Contains(err.Error(), `unknown type acme.Money`) {
+		t.Fatalf("Apply() error = %v, want undefined acme.Money", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "item.proto")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("item.proto was written despite the validation error")
+	}
+}
+
+func TestPlanCaseConflicts(t *testing.T) {
+	source := t.TempDir()
+	writeTree(t, source, map[string]string{
+		"Foo/a.proto": "syntax = \"proto3\";\npackage foo.upper;\n",
+		"foo/a.proto": "syntax = \"proto3\";\npackage foo.lower;\n",
+	})
+	if entries, err := os.ReadDir(source); err != nil || len(entries) != 2 {
+		t.Skip("filesystem folds case")
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		wantErr bool
+	}{
+		{name: "directory target", target: t.TempDir()},
+		{name: "archive target", target: filepath.Join(t.TempDir(), "out.zip"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := New(&config.Config{Source: source, Target: tt.target}, &types.GlobalFlags{})
+			if !tt.wantErr && eng.foldsCase() {
+				t.Skip("target folds case")
+			}
+
+			plan, err := eng.Plan(context.Background())
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "path differs only in case") {
+					t.Fatalf("Plan() error = %v, want a case conflict", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			if len(plan.Files) != 2 || len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0].Message, "path differs only in case") {
+				t.Errorf("files = %d, warnings = %v, want both files and a case warning", len(plan.Files), plan.Warnings)
+			}
+		})
+	}
+}
+
+func TestPlanStrictValidationWarnings(t *testing.T) {
+	source := t.TempDir()
+	writeTree(t, source, map[string]string{
+		"item.proto": "syntax = \"proto3\";\npackage old.v1;\nimport \"acme/money.proto\";\nmessage Item { acme.Money price = 1; }\n",
+	})
+
+	cfg := &config.Config{
+		Source: source,
+		Target: t.TempDir(),
+		Rules:  []config.Rule{{Kind: "package", From: "old.v1", To: "new.v1"}},
+	}
+	_, err := New(cfg, &types.GlobalFlags{Strict: true}).Plan(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "plan has a warning in strict mode") || !strings.Contains(err.Error(), `import "acme/money.proto" was not found`) {
+		t.Fatalf("Plan() error = %v, want the unresolved import in strict mode", err)
+	}
+}
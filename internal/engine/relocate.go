@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackchuka/proto-migrate/internal/loader"
+	"github.com/jackchuka/proto-migrate/internal/resolve"
+)
+
+// importRoots returns the directories import paths are relative to: the
+// include paths, then the working directory.
+func (e *Engine) importRoots() []string {
+	return append(e.includePaths(), ".")
+}
+
+// relocations maps the source directory to the target below every import
+// root containing both, followed by the relocate rules. File locations and
+// import rewrites are both derived from it.
+func (e *Engine) relocations() *resolve.Relocations {
+	var pairs []string
+	for _, root := range e.importRoots() {
+		source, sourceOK := importRoot(e.config.Source, []string{root})
+		target, targetOK := importRoot(e.config.Target, []string{root})
+		if sourceOK && targetOK {
+			pairs = append(pairs, source.name, target.name)
+		}
+	}
+	for _, rule := range e.config.Rules {
+		if rule.Kind == "relocate" {
+			pairs = append(pairs, rule.From, rule.To)
+		}
+	}
+	return resolve.BuildRelocations(e.config.Source, e.config.Target, pairs)
+}
+
+// recordRelocations adds a change for every file a relocate rule moves away
+// from its place below the target directory. It fails if two files would be
+// written to the same path.
+func (e *Engine) recordRelocations(plan *Plan, usage *ruleUsage) error {
+	written := make(map[string]string, len(plan.Files))
+	for _, file := range plan.Files {
+		targetPath, err := plan.TargetPath(file)
+		if err != nil {
+			return err
+		}
+		if other, ok := written[targetPath]; ok {
+			return fmt.Errorf("%s and %s are both written to %s; check the relocate rules", other, file.Path, targetPath)
+		}
+		written[targetPath] = file.Path
+		defaultPath, err := plan.defaultTargetPath(file)
+		if err != nil {
+			return err
+		}
+		if targetPath == defaultPath {
+			continue
+		}
+
+		source, _ := importRoot(file.Path, plan.roots)
+		from, _ := plan.relocations.Match(source.name)
+		for i, rule := range e.config.Rules {
+			if rule.Kind == "relocate" && filepath.ToSlash(filepath.Clean(rule.From)) == from {
+				usage.matched[i]++
+				plan.Changes = append(plan.Changes, Change{
+					File:        file.Path,
+					Type:        "relocate",
+					Description: fmt.Sprintf("Relocated to %s", targetPath),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+type rootedPath struct {
+	root string
+	name string
+}
+
+// importRoot returns path relative to the first root containing it.
+func importRoot(path string, roots []string) (rootedPath, bool) {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rootedPath{root: root, name: filepath.ToSlash(rel)}, true
+		}
+	}
+	return rootedPath{}, false
+}
+
+// relocatedPath returns where the relocations move file, given the path it
+// would have below the target directory.
+func (p *Plan) relocatedPath(file *loader.ProtoFile, defaultPath string) (string, bool) {
+	if p.relocations == nil {
+		return "", false
+	}
+	source, ok := importRoot(file.Path, p.roots)
+	if !ok {
+		return "", false
+	}
+	name, ok := p.relocations.Relocate(source.name)
+	if !ok {
+		return "", false
+	}
+	target, ok := importRoot(defaultPath, p.roots)
+	if !ok || target.name == name {
+		return "", false
+	}
+	return filepath.Join(target.root, filepath.FromSlash(name)), true
+}
+
+// importUpdates rewrites every import that resolves to a loaded file to the
+// path that file is written to. Imports that do not resolve are left alone;
+// those mentioning a relocated prefix are returned as skipped, since they
+// look like they were meant to move as well.
+func importUpdates(plan *Plan) (map[string][]resolve.ImportUpdate, []string, error) {
+	files := plan.Graph.GetFiles()
+	targets := make(map[string]string, len(files))
+	for path, file := range files {
+		targetPath, err := plan.TargetPath(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		targets[path] = targetPath
+	}
+
+	var skipped []string
+	updates := plan.Graph.UpdateImports(func(importer, importPath, target string) string {
+		if target == "" {
+			if plan.relatedImport(importPath) {
+				skipped = append(skipped, fmt.Sprintf("import %q in %s does not resolve to a migrated file; left unchanged", importPath, importer))
+			}
+			return importPath
+		}
+		return plan.importPath(importPath, importer, target, targets)
+	})
+	return updates, skipped, nil
+}
+
+// importPath returns how the file at target imports the file resolved from
+// importPath once both are written out. The import stays relative to the
+// root it was resolved through when the moved file is still below it.
+func (p *Plan) importPath(importPath, importer, target string, targets map[string]string) string {
+	newTarget := targets[target]
+	root, ok := strings.CutSuffix(target, filepath.FromSlash(importPath))
+	if ok && (root == "" || strings.HasSuffix(root, string(filepath.Separator))) {
+		root = filepath.Clean(root)
+		if root == filepath.Dir(importer) {
+			// Resolved next to the importer; keep it relative to where the
+			// importer is written.
+			root = filepath.Dir(targets[importer])
+		}
+		if rooted, ok := importRoot(newTarget, []string{root}); ok {
+			return rooted.name
+		}
+	}
+	return importName(newTarget, p.roots)
+}
+
+// relatedImport reports whether importPath mentions a relocated prefix as
+// whole path segments, e.g. vendor/old/v1/a.proto for old/v1.
+func (p *Plan) relatedImport(importPath string) bool {
+	if p.relocations == nil {
+		return false
+	}
+	for from, to := range p.relocations.PathMap {
+		if from != "." && from != to && strings.Contains("/"+importPath+"/", "/"+from+"/") {
+			return true
+		}
+	}
+	return false
+}
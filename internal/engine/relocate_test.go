@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/jackchuka/proto-migrate/internal/resolve"
+)
+
+func TestRelatedImport(t *testing.T) {
+	plan := &Plan{relocations: resolve.BuildRelocations("proto/old", "proto/new", []string{"oldpackage/v1", "newpackage/v1"})}
+
+	tests := []struct {
+		importPath string
+		want       bool
+	}{
+		{importPath: "oldpackage/v1/a.proto", want: true},
+		{importPath: "vendor/oldpackage/v1/a.proto", want: true},
+		{importPath: "vendor/oldpackage/v1x/a.proto"},
+		{importPath: "myoldpackage/v1/a.proto"},
+		{importPath: "other/a.proto"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.importPath, func(t *testing.T) {
+			if got := plan.relatedImport(tt.importPath); got != tt.want {
+				t.Errorf("relatedImport(%q) = %v, want %v", tt.importPath, got, tt.want)
+			}
+		})
+	}
+}
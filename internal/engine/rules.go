@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jackchuka/proto-migrate/internal/config"
+	"github.com/jackchuka/proto-migrate/internal/transform"
+)
+
+// Warning flags a rule that is unlikely to do what its author intended.
+type Warning struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Rule, w.Message)
+}
+
+// ruleUsage records what each configured rule changed during planning.
+type ruleUsage struct {
+	originals    map[string]string
+	matched      map[int]int
+	changedLines map[int]map[string]map[int]bool
+}
+
+func newRuleUsage() *ruleUsage {
+	return &ruleUsage{
+		originals:    make(map[string]string),
+		matched:      make(map[int]int),
+		changedLines: make(map[int]map[string]map[int]bool),
+	}
+}
+
+func (u *ruleUsage) record(index int, path, before, after string) {
+	u.matched[index]++
+
+	lines := u.changedLines[index]
+	if lines == nil {
+		lines = make(map[string]map[int]bool)
+		u.changedLines[index] = lines
+	}
+	if lines[path] == nil {
+		lines[path] = make(map[int]bool)
+	}
+	for _, line := range changedLines(before, after) {
+		lines[path][line] = true
+	}
+}
+
+// warnings reports rules that were shadowed by an earlier rule, matched
+// nothing, or are regexp rules rewriting lines an AST-based rule also rewrote.
+func (u *ruleUsage) warnings(configs []config.Rule, rules []transform.Rule) []Warning {
+	var warnings []Warning
+
+	for i, cfg := range configs {
+		name := fmt.Sprintf("rules[%d] %s", i, rules[i].ID())
+
+		if j := shadowedBy(configs, i); j >= 0 {
+			warnings = append(warnings, Warning{
+				Rule:    name,
+				Message: fmt.Sprintf("shadowed by rules[%d] %s", j, rules[j].ID()),
+			})
+			continue
+		}
+		if u.matched[i] == 0 {
+			warnings = append(warnings, Warning{Rule: name, Message: "matched no files"})
+			continue
+		}
+		if cfg.Kind == "regexp" {
+			warnings = append(warnings, u.overlaps(configs, rules, i, name)...)
+		}
+	}
+
+	return warnings
+}
+
+// shadowedBy returns the index of an earlier rule with the same kind and
+// match target as rule i, or -1. The earlier rule has already rewritten
+// everything rule i would match.
+func shadowedBy(configs []config.Rule, i int) int {
+	for j := 0; j < i; j++ {
+		if configs[j].Kind == configs[i].Kind && ruleTarget(configs[j]) == ruleTarget(configs[i]) {
+			return j
+		}
+	}
+	return -1
+}
+
+func ruleTarget(cfg config.Rule) string {
+	if cfg.Kind == "regexp" {
+		return cfg.Pattern
+	}
+	return cfg.From
+}
+
+func (u *ruleUsage) overlaps(configs []config.Rule, rules []transform.Rule, i int, name string) []Warning {
+	re, err := regexp.Compile(configs[i].Pattern)
+	if err != nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(u.originals))
+	for path := range u.originals {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var warnings []Warning
+	for j, cfg := range configs {
+		if cfg.Kind == "regexp" {
+			continue
+		}
+		if path, line, ok := u.firstOverlap(re, j, paths); ok {
+			warnings = append(warnings, Warning{
+				Rule:    name,
+				Message: fmt.Sprintf("overlaps rules[%d] %s at %s:%d", j, rules[j].ID(), path, line),
+			})
+		}
+	}
+	return warnings
+}
+
+func (u *ruleUsage) firstOverlap(re *regexp.Regexp, index int, paths []string) (string, int, bool) {
+	for _, path := range paths {
+		lines := u.changedLines[index][path]
+		if len(lines) == 0 {
+			continue
+		}
+
+		original := u.originals[path]
+		for _, loc := range re.FindAllStringIndex(original, -1) {
+			first := strings.Count(original[:loc[0]], "\n") + 1
+			last := first + strings.Count(original[loc[0]:loc[1]], "\n")
+			for line := first; line <= last; line++ {
+				if lines[line] {
+					return path, line, true
+				}
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// changedLines returns the 1-based line numbers of before that differ in after.
+func changedLines(before, after string) []int {
+	a, b := strings.Split(before, "\n"), strings.Split(after, "\n")
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []int
+	for i := prefix; i < len(a)-suffix; i++ {
+		if i < len(b) && len(a) == len(b) && a[i] == b[i] {
+			continue
+		}
+		lines = append(lines, i+1)
+	}
+	return lines
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackchuka/proto-migrate/internal/config"
+	"github.com/jackchuka/proto-migrate/internal/transform"
+)
+
+func TestRuleUsageWarnings(t *testing.T) {
+	configs := []config.Rule{
+		{Kind: "package", From: "old.v1", To: "new.v1"},
+		{Kind: "package", From: "old.v1", To: "other.v1"},
+		{Kind: "service", From: "Missing", To: "Found"},
+		{Kind: "regexp", Pattern: `old\.v1`, Replace: "new.v1"},
+	}
+	var rules []transform.Rule
+	for _, cfg := range configs {
+		rule, err := transform.CreateRule(cfg)
+		if err != nil {
+			t.Fatalf("CreateRule() error = %v", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	original := "syntax = \"proto3\";\npackage old.v1;\n// see old.v1.Item\n"
+	usage := newRuleUsage()
+	usage.originals["a.proto"] = original
+	usage.record(0, "a.proto", original, strings.Replace(original, "package old.v1;", "package new.v1;", 1))
+	usage.record(3, "a.proto", original, strings.ReplaceAll(original, "old.v1", "new.v1"))
+
+	warnings := usage.warnings(configs, rules)
+
+	want := []string{
+		"rules[1] package.rename:old.v1->other.v1: shadowed by rules[0]",
+		"rules[2] service.rename:Missing->Found: matched no files",
+		`rules[3] regexp:old\.v1->new.v1: overlaps rules[0] package.rename:old.v1->new.v1 at a.proto:2`,
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("warnings() returned %d warnings, want %d: %v", len(warnings), len(want), warnings)
+	}
+	for i, w := range want {
+		if !strings.Contains(warnings[i].String(), w) {
+			t.Errorf("warning %d = %q, want it to contain %q", i, warnings[i].String(), w)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GitSource reads files as they were at a revision of the git repository
+// containing the working directory. Paths are interpreted like working tree
+// paths, so a source directory means the same thing with or without a rev.
+type GitSource struct {
+	rev string
+
+	mu    sync.Mutex
+	sizes map[string]int64
+}
+
+func NewGitSource(rev string) *GitSource {
+	return &GitSource{rev: rev, sizes: make(map[string]int64)}
+}
+
+// Rev returns the revision files are read from.
+func (g *GitSource) Rev() string {
+	return g.rev
+}
+
+func (g *GitSource) Files(root string) ([]string, error) {
+	rel, abs, err := relToWorkingDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := g.git("ls-tree", "-r", "-z", "-l", g.rev, "--", rel)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range strings.Split(string(out), "\x00") {
+		meta, path, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing git ls-tree output %q: %w", entry, err)
+		}
+
+		path = filepath.FromSlash(path)
+		if abs {
+			path = filepath.Join(workingDir(), path)
+		}
+		g.mu.Lock()
+		g.sizes[filepath.Clean(path)] = size
+		g.mu.Unlock()
+		paths = append(paths, path)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files under %s at %s", root, g.rev)
+	}
+	return paths, nil
+}
+
+// Stat reports the blob size; content at a revision never changes.
+func (g *GitSource) Stat(path string) (fs.FileInfo, error) {
+	g.mu.Lock()
+	size, ok := g.sizes[filepath.Clean(path)]
+	g.mu.Unlock()
+	if !ok {
+		object, err := g.object(path)
+		if err != nil {
+			return nil, err
+		}
+		out, err := g.git("cat-file", "-s", object)
+		if err != nil {
+			return nil, err
+		}
+		size, err = strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing size of %s: %w", object, err)
+		}
+	}
+	return staticFileInfo{name: filepath.Base(path), size: size}, nil
+}
+
+func (g *GitSource) ReadFile(path string) ([]byte, error) {
+	object, err := g.object(path)
+	if err != nil {
+		return nil, err
+	}
+	return g.git("cat-file", "blob", object)
+}
+
+// object returns the "<rev>:./<path>" name of path, relative to the working
+// directory as git expects for paths starting with "./".
+func (g *GitSource) object(path string) (string, error) {
+	rel, _, err := relToWorkingDir(path)
+	if err != nil {
+		return "", err
+	}
+	return g.rev + ":./" + filepath.ToSlash(rel), nil
+}
+
+func (g *GitSource) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+func relToWorkingDir(path string) (string, bool, error) {
+	if !filepath.IsAbs(path) {
+		return filepath.Clean(path), false, nil
+	}
+	rel, err := filepath.Rel(workingDir(), path)
+	if err != nil {
+		return "", true, fmt.Errorf("locating %s in the repository: %w", path, err)
+	}
+	return rel, true, nil
+}
+
+func workingDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	return dir
+}
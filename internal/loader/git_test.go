@@ -0,0 +1,69 @@
+package loader
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	committed := "syntax = \"proto3\";\npackage old.v1;\n"
+	if err := os.MkdirAll(filepath.Join("proto", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeProto(t, filepath.Join("proto", "a.proto"), committed, time.Now())
+	writeProto(t, filepath.Join("proto", "sub", "b.proto"), committed, time.Now())
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	// Working tree edits must not leak into the loaded revision.
+	writeProto(t, filepath.Join("proto", "a.proto"), "syntax = \"proto3\";\npackage edited.v1;\n", time.Now())
+	writeProto(t, filepath.Join("proto", "c.proto"), committed, time.Now())
+
+	for _, root := range []string{"proto", filepath.Join(dir, "proto")} {
+		t.Run(root, func(t *testing.T) {
+			files, err := NewFromSource(NewGitSource("HEAD"), nil, nil).LoadDirectory(root)
+			if err != nil {
+				t.Fatalf("LoadDirectory() error = %v", err)
+			}
+
+			want := []string{filepath.Join(root, "a.proto"), filepath.Join(root, "sub", "b.proto")}
+			if len(files) != len(want) {
+				t.Fatalf("got %d files, want %d", len(files), len(want))
+			}
+			for i, f := range files {
+				if f.Path != want[i] {
+					t.Errorf("file %d path = %q, want %q", i, f.Path, want[i])
+				}
+				if f.Content != committed || f.Original != committed {
+					t.Errorf("file %d content = %q, want committed content", i, f.Content)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown revision", func(t *testing.T) {
+		if _, err := NewFromSource(NewGitSource("no-such-rev"), nil, nil).LoadDirectory("proto"); err == nil {
+			t.Error("expected an error for an unknown revision")
+		}
+	})
+}
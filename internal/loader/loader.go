@@ -1,160 +1,332 @@
 package loader
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
-	"io"
-	"io/fs"
-	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/emicklei/proto"
 )
 
 type Loader struct {
+	source   Source
+	includes []string
 	excludes []string
-	mu       sync.Mutex
-	cache    map[string]*proto.Proto
+	skipped  []Skip
+	// caseConflicts are the loaded paths differing only in case from another.
+	caseConflicts []ParseError
+	mu            sync.Mutex
+	cache         map[string]*cacheEntry
+}
+
+// cacheEntry is a parsed file together with what is needed to tell whether
+// the file changed since it was read. The parsed AST is shared between loads
+// and must be treated as read-only.
+type cacheEntry struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+	content string
+	proto   *proto.Proto
 }
 
 func New(excludes []string) *Loader {
+	return NewFromSource(OSSource{}, nil, excludes)
+}
+
+// NewFromSource returns a loader that reads files from source. With includes
+// set, only files matching one of them are loaded.
+func NewFromSource(source Source, includes, excludes []string) *Loader {
 	return &Loader{
+		source:   source,
+		includes: includes,
 		excludes: excludes,
-		cache:    make(map[string]*proto.Proto),
+		cache:    make(map[string]*cacheEntry),
 	}
 }
 
+// LoadDirectory loads every .proto file below root, parsing in parallel.
+// Files are returned sorted by path so that plans are reproducible.
 func (l *Loader) LoadDirectory(root string) ([]*ProtoFile, error) {
-	var files []*ProtoFile
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-	errCh := make(chan error, 1)
+	all, err := l.source.Files(root)
+	if err != nil {
+		return nil, err
+	}
 
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
+	var paths []string
+	var skipped []Skip
+	for _, path := range all {
+		if !strings.HasSuffix(path, ".proto") {
+			continue
 		}
-
-		if d.IsDir() || !strings.HasSuffix(path, ".proto") {
-			return nil
+		if skip, ok := l.skip(path, root); ok {
+			skipped = append(skipped, skip)
+			continue
 		}
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].Path < skipped[j].Path
+	})
+	l.mu.Lock()
+	l.skipped = skipped
+	l.mu.Unlock()
+
+	files := make([]*ProtoFile, len(paths))
+	errs := make([]*ParseError, len(paths))
+	dups, caseConflicts := duplicates(l.source, paths)
+	l.mu.Lock()
+	l.caseConflicts = caseConflicts
+	l.mu.Unlock()
+	var wg sync.WaitGroup
 
-		if l.shouldExclude(path, root) {
-			return nil
+	for i, path := range paths {
+		if err, ok := dups[path]; ok {
+			errs[i] = err
+			continue
 		}
 
 		wg.Add(1)
-		go func(p string) {
+		go func(i int, p string) {
 			defer wg.Done()
 
 			pf, err := l.LoadFile(p)
 			if err != nil {
-				select {
-				case errCh <- fmt.Errorf("loading %s: %w", p, err):
-				default:
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) {
+					parseErr = &ParseError{File: p, Message: err.Error()}
 				}
+				errs[i] = parseErr
 				return
 			}
 
-			mu.Lock()
-			files = append(files, pf)
-			mu.Unlock()
-		}(path)
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
+			files[i] = pf
+		}(i, path)
 	}
 
 	wg.Wait()
 
-	select {
-	case err := <-errCh:
-		return nil, err
-	default:
+	loadErr := &Error{}
+	for _, err := range errs {
+		if err != nil {
+			loadErr.Errors = append(loadErr.Errors, *err)
+		}
+	}
+	if len(loadErr.Errors) > 0 {
+		return nil, loadErr
 	}
 
 	return files, nil
 }
 
+// LoadFile reads and parses path. Files whose modification time and size are
+// unchanged since the last load are served from the cache; otherwise the file
+// is re-read and only re-parsed when its content hash differs.
 func (l *Loader) LoadFile(path string) (*ProtoFile, error) {
-	l.mu.Lock()
-	if cached, ok := l.cache[path]; ok {
-		l.mu.Unlock()
-		return &ProtoFile{
-			Path:  path,
-			Proto: cached,
-		}, nil
-	}
-	l.mu.Unlock()
+	key := filepath.Clean(path)
 
-	file, err := os.Open(path)
+	info, err := l.source.Stat(path)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	content, err := io.ReadAll(file)
+	l.mu.Lock()
+	entry, ok := l.cache[key]
+	l.mu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.file(path), nil
+	}
+
+	content, err := l.source.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	hash := sha256.Sum256(content)
 
-	reader := strings.NewReader(string(content))
-	parser := proto.NewParser(reader)
-	parser.Filename(path)
-
-	definition, err := parser.Parse()
-	if err != nil {
-		return nil, fmt.Errorf("parsing proto: %w", err)
+	if ok && entry.hash == hash {
+		entry = &cacheEntry{modTime: info.ModTime(), size: info.Size(), hash: hash, content: entry.content, proto: entry.proto}
+	} else {
+		definition, err := Parse(path, string(content))
+		if err != nil {
+			l.Invalidate(path)
+			return nil, newParseError(path, err)
+		}
+		entry = &cacheEntry{modTime: info.ModTime(), size: info.Size(), hash: hash, content: string(content), proto: definition}
 	}
 
 	l.mu.Lock()
-	l.cache[path] = definition
+	l.cache[key] = entry
 	l.mu.Unlock()
 
+	return entry.file(path), nil
+}
+
+// Invalidate drops path from the cache so the next load re-reads it.
+func (l *Loader) Invalidate(path string) {
+	l.mu.Lock()
+	delete(l.cache, filepath.Clean(path))
+	l.mu.Unlock()
+}
+
+func (e *cacheEntry) file(path string) *ProtoFile {
 	return &ProtoFile{
-		Path:    path,
-		Proto:   definition,
-		Content: string(content),
-	}, nil
+		Path:     path,
+		Proto:    e.proto,
+		Content:  e.content,
+		Original: e.content,
+	}
 }
 
-func (l *Loader) shouldExclude(path, root string) bool {
+// Parse parses proto source content, attributing positions to path.
+func Parse(path, content string) (*proto.Proto, error) {
+	parser := proto.NewParser(strings.NewReader(content))
+	parser.Filename(path)
+	return parser.Parse()
+}
+
+// Skip is a .proto file left out of a load and the reason why.
+type Skip struct {
+	Path string `json:"path"`
+	// Pattern is the exclude pattern that matched, or empty when the file
+	// matched none of the include patterns.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Skipped returns the files left out by the includes and excludes during the
+// last LoadDirectory, sorted by path.
+func (l *Loader) Skipped() []Skip {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.skipped
+}
+
+// CaseConflicts returns the files of the last LoadDirectory whose paths
+// differ only in case from another file's. Both are loaded; they clash only
+// when written to a case-insensitive filesystem.
+func (l *Loader) CaseConflicts() []ParseError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.caseConflicts
+}
+
+// skip reports whether path is filtered out: it matches an exclude pattern,
+// or includes are set and it matches none of them. Excludes win.
+func (l *Loader) skip(path, root string) (Skip, bool) {
+	for _, pattern := range l.excludes {
+		if matchPattern(pattern, path, root) {
+			return Skip{Path: path, Pattern: pattern}, true
+		}
+	}
+	if len(l.includes) == 0 {
+		return Skip{}, false
+	}
+	for _, pattern := range l.includes {
+		if matchPattern(pattern, path, root) {
+			return Skip{}, false
+		}
+	}
+	return Skip{Path: path}, true
+}
+
+func matchPattern(pattern, path, root string) bool {
 	// Calculate relative path from root
 	relativePath, err := filepath.Rel(root, path)
 	if err != nil {
 		relativePath = path
 	}
 
-	for _, pattern := range l.excludes {
-		// Try relative path matching (primary method)
-		if matched, _ := doublestar.Match(pattern, relativePath); matched {
-			return true
-		}
+	// Try relative path matching (primary method)
+	if matched, _ := doublestar.Match(pattern, relativePath); matched {
+		return true
+	}
 
-		// Try full path matching
-		if matched, _ := doublestar.Match(pattern, path); matched {
-			return true
-		}
+	// Try full path matching
+	if matched, _ := doublestar.Match(pattern, path); matched {
+		return true
+	}
 
-		// (patterns without directory separators)
-		if !strings.Contains(pattern, "/") && !strings.Contains(pattern, "**") {
-			if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-				return true
-			}
+	// (patterns without directory separators)
+	if !strings.Contains(pattern, "/") && !strings.Contains(pattern, "**") {
+		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+			return true
 		}
 	}
 	return false
 }
 
+// ProtoFile is a loaded file. Rules rewrite Content; Proto and Original keep
+// the file as it was loaded.
 type ProtoFile struct {
-	Path    string
-	Proto   *proto.Proto
-	Content string
+	Path     string
+	Proto    *proto.Proto
+	Content  string
+	Original string
+}
+
+// ParseError is a file that failed to load, positioned at the parser error
+// when there is one.
+type ParseError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// newParseError splits the "file:line:col: message" form used by the parser.
+func newParseError(path string, err error) *ParseError {
+	parseErr := &ParseError{File: path, Message: err.Error()}
+
+	rest, ok := strings.CutPrefix(err.Error(), path+":")
+	if !ok {
+		return parseErr
+	}
+	parts := strings.SplitN(rest, ":", 3)
+	if len(parts) != 3 {
+		return parseErr
+	}
+	line, lineErr := strconv.Atoi(parts[0])
+	column, columnErr := strconv.Atoi(parts[1])
+	if lineErr != nil || columnErr != nil {
+		return parseErr
+	}
+
+	parseErr.Line = line
+	parseErr.Column = column
+	parseErr.Message = strings.TrimSpace(parts[2])
+	return parseErr
+}
+
+// Error reports every file of a directory that failed to load.
+type Error struct {
+	Errors []ParseError `json:"errors"`
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	count := fmt.Sprintf("%d files", len(e.Errors))
+	if len(e.Errors) == 1 {
+		count = "1 file"
+	}
+	fmt.Fprintf(&b, "%s failed to load:", count)
+	for _, err := range e.Errors {
+		b.WriteString("\n  ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
 }
@@ -0,0 +1,341 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadFileCache(t *testing.T) {
+	original := "syntax = \"proto3\";\npackage old.v1;\n"
+	path := filepath.Join(t.TempDir(), "a.proto")
+	writeProto(t, path, original, time.Now().Add(-time.Hour))
+
+	l := New(nil)
+	first, err := l.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	t.Run("cache hit keeps content", func(t *testing.T) {
+		second, err := l.LoadFile(path)
+		if err != nil {
+			t.Fatalf("LoadFile() error = %v", err)
+		}
+		if second.Content != original {
+			t.Errorf("Content = %q, want %q", second.Content, original)
+		}
+		if second.Proto != first.Proto {
+			t.Error("expected the cached AST to be reused")
+		}
+		if second == first {
+			t.Error("expected a fresh ProtoFile per load")
+		}
+	})
+
+	t.Run("touched but unchanged file is not re-parsed", func(t *testing.T) {
+		writeProto(t, path, original, time.Now().Add(-time.Minute))
+		again, err := l.LoadFile(path)
+		if err != nil {
+			t.Fatalf("LoadFile() error = %v", err)
+		}
+		if again.Proto != first.Proto {
+			t.Error("expected the cached AST to be reused")
+		}
+	})
+
+	t.Run("changed file is reloaded", func(t *testing.T) {
+		changed := "syntax = \"proto3\";\npackage new.v1;\n"
+		writeProto(t, path, changed, time.Now())
+		reloaded, err := l.LoadFile(path)
+		if err != nil {
+			t.Fatalf("LoadFile() error = %v", err)
+		}
+		if reloaded.Content != changed {
+			t.Errorf("Content = %q, want %q", reloaded.Content, changed)
+		}
+		if reloaded.Proto == first.Proto {
+			t.Error("expected the file to be re-parsed")
+		}
+	})
+
+	t.Run("invalidate forces a reload", func(t *testing.T) {
+		before, err := l.LoadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Invalidate(path)
+		after, err := l.LoadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.Proto == before.Proto {
+			t.Error("expected the file to be re-parsed after Invalidate")
+		}
+	})
+}
+
+func TestLoadDirectoryTwice(t *testing.T) {
+	dir := t.TempDir()
+	content := "syntax = \"proto3\";\npackage old.v1;\n"
+	writeProto(t, filepath.Join(dir, "a.proto"), content, time.Now())
+
+	l := New(nil)
+	for i := range 2 {
+		files, err := l.LoadDirectory(dir)
+		if err != nil {
+			t.Fatalf("LoadDirectory() error = %v", err)
+		}
+		if len(files) != 1 {
+			t.Fatalf("load %d: got %d files, want 1", i, len(files))
+		}
+		if files[0].Content != content {
+			t.Errorf("load %d: content %q, want %q", i, files[0].Content, content)
+		}
+	}
+}
+
+func writeProto(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDirectoryOrder(t *testing.T) {
+	dir := t.TempDir()
+	var want []string
+	for i := range 20 {
+		sub := filepath.Join(dir, fmt.Sprintf("pkg%d", i%7), fmt.Sprintf("v%d", i%3))
+		if err := os.MkdirAll(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for j := range 15 {
+			path := filepath.Join(sub, fmt.Sprintf("file_%d_%d.proto", i, j))
+			writeProto(t, path, "syntax = \"proto3\";\npackage p.v1;\n", time.Now())
+			want = append(want, path)
+		}
+	}
+	sort.Strings(want)
+
+	for run := range 5 {
+		files, err := New(nil).LoadDirectory(dir)
+		if err != nil {
+			t.Fatalf("LoadDirectory() error = %v", err)
+		}
+		got := make([]string, len(files))
+		for i, f := range files {
+			got[i] = f.Path
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: files not in lexical order", run)
+		}
+	}
+}
+
+func TestLoadDirectoryErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeProto(t, filepath.Join(dir, "a.proto"), "syntax = \"proto3\";\nmessage A { string x = ; }\n", time.Now())
+	writeProto(t, filepath.Join(dir, "b.proto"), "syntax = \"proto3\";\nmessage B {}\n", time.Now())
+	writeProto(t, filepath.Join(dir, "c.proto"), "syntax = \"proto3\";\nmessage C {\n", time.Now())
+
+	_, err := New(nil).LoadDirectory(dir)
+	var loadErr *Error
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("LoadDirectory() error = %v, want *Error", err)
+	}
+
+	want := []ParseError{
+		{File: filepath.Join(dir, "a.proto"), Line: 2, Column: 24},
+		{File: filepath.Join(dir, "c.proto"), Line: 3, Column: 1},
+	}
+	if len(loadErr.Errors) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(loadErr.Errors), len(want), loadErr)
+	}
+	for i, w := range want {
+		got := loadErr.Errors[i]
+		if got.File != w.File || got.Line != w.Line || got.Column != w.Column || got.Message == "" {
+			t.Errorf("error %d = %+v, want position %s:%d:%d", i, got, w.File, w.Line, w.Column)
+		}
+	}
+}
+
+func TestErrorCount(t *testing.T) {
+	tests := []struct {
+		errors int
+		want   string
+	}{
+		{errors: 1, want: "1 file failed to load:"},
+		{errors: 2, want: "2 files failed to load:"},
+	}
+	for _, tt := range tests {
+		err := &Error{Errors: make([]ParseError, tt.errors)}
+		if got := err.Error(); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("Error() = %q, want prefix %q", got, tt.want)
+		}
+	}
+}
+
+func TestLoadDirectorySymlinks(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	shared := filepath.Join(dir, "shared")
+	for _, d := range []string{src, shared} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content := "syntax = \"proto3\";\n"
+	writeProto(t, filepath.Join(src, "a.proto"), content, time.Now())
+	writeProto(t, filepath.Join(shared, "b.proto"), content, time.Now())
+	for link, target := range map[string]string{
+		filepath.Join(src, "linked"): shared,
+		filepath.Join(src, "loop"):   src,
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	tests := []struct {
+		policy  SymlinkPolicy
+		want    []string
+		wantErr bool
+	}{
+		{policy: SymlinkFollow, want: []string{"a.proto", filepath.Join("linked", "b.proto")}},
+		{policy: "", want: []string{"a.proto", filepath.Join("linked", "b.proto")}},
+		{policy: SymlinkSkip, want: []string{"a.proto"}},
+		{policy: SymlinkError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			files, err := NewFromSource(OSSource{Symlinks: tt.policy}, nil, nil).LoadDirectory(src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadDirectory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, f := range files {
+				rel, _ := filepath.Rel(src, f.Path)
+				got = append(got, rel)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDirectoryDuplicates(t *testing.T) {
+	content := []byte("syntax = \"proto3\";\n")
+
+	t.Run("same file through a symlink", func(t *testing.T) {
+		dir := t.TempDir()
+		writeProto(t, filepath.Join(dir, "a.proto"), string(content), time.Now())
+		if err := os.Symlink(filepath.Join(dir, "a.proto"), filepath.Join(dir, "alias.proto")); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+
+		_, err := New(nil).LoadDirectory(dir)
+		var loadErr *Error
+		if !errors.As(err, &loadErr) || len(loadErr.Errors) != 1 {
+			t.Fatalf("LoadDirectory() error = %v, want one duplicate", err)
+		}
+		if got := loadErr.Errors[0]; got.File != filepath.Join(dir, "alias.proto") || got.Message != "same file as "+filepath.Join(dir, "a.proto") {
+			t.Errorf("error = %+v", got)
+		}
+	})
+
+	t.Run("paths differing only in case", func(t *testing.T) {
+		source := NewMemorySource("api", func() (map[string][]byte, error) {
+			return map[string][]byte{"Foo/a.proto": content, "foo/a.proto": content}, nil
+		})
+		l := NewFromSource(source, nil, nil)
+		files, err := l.LoadDirectory("api")
+		if err != nil {
+			t.Fatalf("LoadDirectory() error = %v", err)
+		}
+		if len(files) != 2 {
+			t.Errorf("loaded %d files, want both", len(files))
+		}
+		conflicts := l.CaseConflicts()
+		if len(conflicts) != 1 {
+			t.Fatalf("CaseConflicts() = %v, want one", conflicts)
+		}
+		if got := conflicts[0].Message; got != "path differs only in case from "+filepath.Join("api", "Foo", "a.proto") {
+			t.Errorf("message = %q", got)
+		}
+	})
+}
+
+func TestLoadDirectoryIncludes(t *testing.T) {
+	dir := t.TempDir()
+	for _, rel := range []string{"a/v1/x.proto", "a/v1/x_test.proto", "a/v2/y.proto", "b/v1/deep/z.proto"} {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeProto(t, path, "syntax = \"proto3\";\n", time.Now())
+	}
+
+	tests := []struct {
+		name     string
+		includes []string
+		excludes []string
+		want     []string
+		skipped  []Skip
+	}{
+		{
+			name: "no patterns",
+			want: []string{"a/v1/x.proto", "a/v1/x_test.proto", "a/v2/y.proto", "b/v1/deep/z.proto"},
+		},
+		{
+			name:     "includes only",
+			includes: []string{"**/v1/**/*.proto"},
+			want:     []string{"a/v1/x.proto", "a/v1/x_test.proto", "b/v1/deep/z.proto"},
+			skipped:  []Skip{{Path: "a/v2/y.proto"}},
+		},
+		{
+			name:     "excludes win over includes",
+			includes: []string{"**/v1/**/*.proto"},
+			excludes: []string{"*_test.proto"},
+			want:     []string{"a/v1/x.proto", "b/v1/deep/z.proto"},
+			skipped:  []Skip{{Path: "a/v1/x_test.proto", Pattern: "*_test.proto"}, {Path: "a/v2/y.proto"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewFromSource(OSSource{}, tt.includes, tt.excludes)
+			files, err := l.LoadDirectory(dir)
+			if err != nil {
+				t.Fatalf("LoadDirectory() error = %v", err)
+			}
+			var got []string
+			for _, f := range files {
+				rel, _ := filepath.Rel(dir, f.Path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+			var skipped []Skip
+			for _, s := range l.Skipped() {
+				rel, _ := filepath.Rel(dir, s.Path)
+				skipped = append(skipped, Skip{Path: filepath.ToSlash(rel), Pattern: s.Pattern})
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("Skipped() = %v, want %v", skipped, tt.skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package loader
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemorySource serves files held in memory below a virtual root, such as the
+// entries of an archive. load returns the files keyed by slash-separated path
+// relative to the root and runs on first use.
+type MemorySource struct {
+	root string
+	load func() (map[string][]byte, error)
+
+	once  sync.Once
+	files map[string][]byte
+	err   error
+}
+
+func NewMemorySource(root string, load func() (map[string][]byte, error)) *MemorySource {
+	return &MemorySource{root: root, load: load}
+}
+
+func (s *MemorySource) init() error {
+	s.once.Do(func() {
+		var files map[string][]byte
+		files, s.err = s.load()
+		s.files = make(map[string][]byte, len(files))
+		for name, content := range files {
+			s.files[filepath.Join(s.root, filepath.FromSlash(name))] = content
+		}
+	})
+	return s.err
+}
+
+func (s *MemorySource) Files(root string) ([]string, error) {
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	if filepath.Clean(root) != filepath.Clean(s.root) {
+		return nil, fmt.Errorf("%s cannot list %s", s.root, root)
+	}
+
+	paths := make([]string, 0, len(s.files))
+	for path := range s.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (s *MemorySource) Stat(path string) (fs.FileInfo, error) {
+	content, err := s.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return staticFileInfo{name: filepath.Base(path), size: int64(len(content))}, nil
+}
+
+func (s *MemorySource) ReadFile(path string) ([]byte, error) {
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	content, ok := s.files[filepath.Clean(path)]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	}
+	return content, nil
+}
+
+// staticFileInfo describes a file whose content cannot change while it is
+// loaded, so its modification time is always zero.
+type staticFileInfo struct {
+	name string
+	size int64
+}
+
+func (i staticFileInfo) Name() string       { return i.name }
+func (i staticFileInfo) Size() int64        { return i.size }
+func (i staticFileInfo) Mode() fs.FileMode  { return 0444 }
+func (i staticFileInfo) ModTime() time.Time { return time.Time{} }
+func (i staticFileInfo) IsDir() bool        { return false }
+func (i staticFileInfo) Sys() any           { return nil }
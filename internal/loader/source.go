@@ -0,0 +1,153 @@
+package loader
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source is a tree of files the loader reads from.
+type Source interface {
+	// Files returns the path of every regular file below root.
+	Files(root string) ([]string, error)
+	Stat(path string) (fs.FileInfo, error)
+	ReadFile(path string) ([]byte, error)
+}
+
+// SymlinkPolicy says what OSSource does with symlinks below the root.
+type SymlinkPolicy string
+
+const (
+	SymlinkFollow SymlinkPolicy = "follow"
+	SymlinkSkip   SymlinkPolicy = "skip"
+	SymlinkError  SymlinkPolicy = "error"
+)
+
+// OSSource reads files from the working tree. Symlinks are followed unless
+// the policy says otherwise; the root itself is always followed.
+type OSSource struct {
+	Symlinks SymlinkPolicy
+}
+
+func (s OSSource) Files(root string) ([]string, error) {
+	var paths []string
+
+	// walk lists dir, which is reached through the linked directories in
+	// stack. Links back into the stack or to an ancestor would never end.
+	var walk func(dir string, stack map[string]bool) error
+	walk = func(dir string, stack map[string]bool) error {
+		// The trailing separator makes WalkDir descend into a linked root.
+		return filepath.WalkDir(dir+string(filepath.Separator), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			path = filepath.Clean(path)
+
+			if d.Type()&fs.ModeSymlink == 0 {
+				if !d.IsDir() {
+					paths = append(paths, path)
+				}
+				return nil
+			}
+
+			switch s.Symlinks {
+			case SymlinkSkip:
+				return nil
+			case SymlinkError:
+				return fmt.Errorf("%s is a symlink (symlinks: error)", path)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("following symlink %s: %w", path, err)
+			}
+			if !info.IsDir() {
+				paths = append(paths, path)
+				return nil
+			}
+
+			target := CanonicalPath(path)
+			parent := CanonicalPath(filepath.Dir(path))
+			if stack[target] || parent == target || strings.HasPrefix(parent, target+string(filepath.Separator)) {
+				return nil
+			}
+			inner := make(map[string]bool, len(stack)+1)
+			for k := range stack {
+				inner[k] = true
+			}
+			inner[target] = true
+			return walk(path, inner)
+		})
+	}
+
+	root = filepath.Clean(root)
+	if err := walk(root, map[string]bool{CanonicalPath(root): true}); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func (OSSource) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (OSSource) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// Canonical returns the path of the file a path refers to on disk.
+func (OSSource) Canonical(path string) string {
+	return CanonicalPath(path)
+}
+
+// CanonicalPath returns path made absolute with all symlinks resolved, or
+// just absolute and clean when it does not exist.
+func CanonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// canonicalizer is implemented by sources whose files can be reached by more
+// than one path.
+type canonicalizer interface {
+	Canonical(path string) string
+}
+
+// duplicates reports files that are reachable by more than one of paths,
+// keeping the first path in order, and separately the paths that differ only
+// in case from an earlier one. Those cannot coexist on case-insensitive
+// filesystems but are distinct files elsewhere.
+func duplicates(source Source, paths []string) (map[string]*ParseError, []ParseError) {
+	errs := make(map[string]*ParseError)
+	var conflicts []ParseError
+	seen := make(map[string]string)
+	folded := make(map[string]string)
+
+	for _, path := range paths {
+		key := filepath.Clean(path)
+		if c, ok := source.(canonicalizer); ok {
+			key = c.Canonical(path)
+		}
+
+		if first, ok := seen[key]; ok {
+			errs[path] = &ParseError{File: path, Message: fmt.Sprintf("same file as %s", first)}
+			continue
+		}
+		seen[key] = path
+
+		lower := strings.ToLower(filepath.ToSlash(path))
+		if first, ok := folded[lower]; ok {
+			conflicts = append(conflicts, ParseError{File: path, Message: fmt.Sprintf("path differs only in case from %s", first)})
+			continue
+		}
+		folded[lower] = path
+	}
+	return errs, conflicts
+}
@@ -0,0 +1,130 @@
+package resolve
+
+import (
+	"slices"
+	"sort"
+)
+
+// Cycles returns the elementary import cycles among the loaded files, at most
+// maxCycles per strongly connected component. Each cycle is listed in import
+// order starting from its lexically first file; the last file imports the
+// first. Call ResolveImports first.
+func (g *Graph) Cycles() [][]string {
+	edges := make(map[string][]string, len(g.files))
+	nodes := make([]string, 0, len(g.files))
+	for path := range g.files {
+		nodes = append(nodes, path)
+		seen := make(map[string]bool)
+		for _, imp := range g.imports[path] {
+			if target, ok := g.resolved[path][imp]; ok && !seen[target] {
+				seen[target] = true
+				edges[path] = append(edges[path], target)
+			}
+		}
+		sort.Strings(edges[path])
+	}
+	sort.Strings(nodes)
+
+	var cycles [][]string
+	for _, component := range stronglyConnected(nodes, edges) {
+		cycles = append(cycles, elementaryCycles(component, edges)...)
+	}
+	slices.SortFunc(cycles, slices.Compare)
+	return cycles
+}
+
+// maxCycles bounds the cycles listed per component, since their number can
+// grow exponentially with its size.
+const maxCycles = 32
+
+// stronglyConnected returns the strongly connected components of the graph
+// using Tarjan's algorithm.
+func stronglyConnected(nodes []string, edges map[string][]string) [][]string {
+	index := make(map[string]int, len(nodes))
+	low := make(map[string]int, len(nodes))
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var visit func(string)
+	visit = func(node string) {
+		index[node] = len(index)
+		low[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range edges[node] {
+			if _, ok := index[next]; !ok {
+				visit(next)
+				low[node] = min(low[node], low[next])
+			} else if onStack[next] {
+				low[node] = min(low[node], index[next])
+			}
+		}
+
+		if low[node] == index[node] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == node {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, node := range nodes {
+		if _, ok := index[node]; !ok {
+			visit(node)
+		}
+	}
+	return components
+}
+
+// elementaryCycles returns the cycles within component, each starting from
+// its lexically first file, up to maxCycles. A single file not importing
+// itself has none.
+func elementaryCycles(component []string, edges map[string][]string) [][]string {
+	sort.Strings(component)
+	inComponent := make(map[string]bool, len(component))
+	for _, node := range component {
+		inComponent[node] = true
+	}
+
+	var cycles [][]string
+	for _, start := range component {
+		// Depth-first search for paths back to start through files sorting
+		// after it, so that each cycle is found once, from its first file.
+		path := []string{start}
+		onPath := map[string]bool{start: true}
+		var visit func(node string) bool
+		visit = func(node string) bool {
+			for _, next := range edges[node] {
+				switch {
+				case next == start:
+					cycles = append(cycles, slices.Clone(path))
+					if len(cycles) == maxCycles {
+						return false
+					}
+				case inComponent[next] && next > start && !onPath[next]:
+					path = append(path, next)
+					onPath[next] = true
+					if !visit(next) {
+						return false
+					}
+					path = path[:len(path)-1]
+					onPath[next] = false
+				}
+			}
+			return true
+		}
+		if !visit(start) {
+			break
+		}
+	}
+	return cycles
+}
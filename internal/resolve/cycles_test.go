@@ -0,0 +1,100 @@
+package resolve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/emicklei/proto"
+	"github.com/jackchuka/proto-migrate/internal/loader"
+)
+
+func TestCycles(t *testing.T) {
+	tests := []struct {
+		name    string
+		imports map[string][]string
+		want    [][]string
+	}{
+		{
+			name:    "acyclic",
+			imports: map[string][]string{"a.proto": {"b.proto"}, "b.proto": {"c.proto"}, "c.proto": nil},
+		},
+		{
+			name:    "self import",
+			imports: map[string][]string{"a.proto": {"a.proto"}},
+			want:    [][]string{{"a.proto"}},
+		},
+		{
+			name: "every cycle through the first file",
+			imports: map[string][]string{
+				"a.proto": {"b.proto"},
+				"b.proto": {"c.proto", "d.proto"},
+				"c.proto": {"d.proto"},
+				"d.proto": {"a.proto"},
+			},
+			want: [][]string{{"a.proto", "b.proto", "c.proto", "d.proto"}, {"a.proto", "b.proto", "d.proto"}},
+		},
+		{
+			name: "independent cycles in one component",
+			imports: map[string][]string{
+				"a.proto": {"b.proto", "c.proto"},
+				"b.proto": {"a.proto"},
+				"c.proto": {"a.proto", "d.proto"},
+				"d.proto": {"c.proto"},
+			},
+			want: [][]string{{"a.proto", "b.proto"}, {"a.proto", "c.proto"}, {"c.proto", "d.proto"}},
+		},
+		{
+			name: "cycles in separate components",
+			imports: map[string][]string{
+				"x.proto": {"y.proto"},
+				"y.proto": {"x.proto", "a.proto"},
+				"a.proto": {"b.proto"},
+				"b.proto": {"a.proto", "google/protobuf/empty.proto"},
+			},
+			want: [][]string{{"a.proto", "b.proto"}, {"x.proto", "y.proto"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGraph()
+			for path, imports := range tt.imports {
+				var elements []proto.Visitee
+				for _, imp := range imports {
+					elements = append(elements, &proto.Import{Filename: imp})
+				}
+				g.AddFile(&loader.ProtoFile{Path: path, Proto: &proto.Proto{Elements: elements}})
+			}
+			if err := g.ResolveImports(".", nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := g.Cycles(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Cycles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCyclesLimit(t *testing.T) {
+	// Every file importing every other one has far more than maxCycles
+	// cycles.
+	files := []string{"a.proto", "b.proto", "c.proto", "d.proto", "e.proto", "f.proto"}
+	g := NewGraph()
+	for _, path := range files {
+		var elements []proto.Visitee
+		for _, imp := range files {
+			if imp != path {
+				elements = append(elements, &proto.Import{Filename: imp})
+			}
+		}
+		g.AddFile(&loader.ProtoFile{Path: path, Proto: &proto.Proto{Elements: elements}})
+	}
+	if err := g.ResolveImports(".", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(g.Cycles()); got != maxCycles {
+		t.Errorf("len(Cycles()) = %d, want %d", got, maxCycles)
+	}
+}
@@ -1,7 +1,10 @@
 package resolve
 
 import (
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/emicklei/proto"
@@ -12,19 +15,72 @@ type Graph struct {
 	files    map[string]*loader.ProtoFile
 	imports  map[string][]string
 	external map[string]bool
+	siblings map[string]string
+	resolved map[string]map[string]string
+
+	// canonical and folded map the on-disk path of each file, as is and
+	// lower-cased, back to its key in files.
+	canonical map[string]string
+	folded    map[string]string
+
+	// symbols holds the definitions of each fully-qualified name and
+	// packages every package and package prefix. uses are the type names
+	// as written; references indexes them by resolved symbol and is built
+	// on first use.
+	symbols    map[string][]Symbol
+	packages   map[string]bool
+	uses       []typeUse
+	references map[string][]Reference
 }
 
 func NewGraph() *Graph {
 	return &Graph{
-		files:    make(map[string]*loader.ProtoFile),
-		imports:  make(map[string][]string),
-		external: make(map[string]bool),
+		files:     make(map[string]*loader.ProtoFile),
+		imports:   make(map[string][]string),
+		external:  make(map[string]bool),
+		siblings:  make(map[string]string),
+		resolved:  make(map[string]map[string]string),
+		canonical: make(map[string]string),
+		folded:    make(map[string]string),
+		symbols:   make(map[string][]Symbol),
+		packages:  make(map[string]bool),
 	}
 }
 
 func (g *Graph) AddFile(file *loader.ProtoFile) {
-	g.files[file.Path] = file
-	g.imports[file.Path] = g.extractImports(file)
+	path := filepath.Clean(file.Path)
+	g.files[path] = file
+	g.imports[path] = g.extractImports(file)
+	g.addSymbols(path, file)
+
+	canonical := loader.CanonicalPath(path)
+	g.canonical[canonical] = path
+	g.folded[strings.ToLower(canonical)] = path
+}
+
+// lookup returns the key of the loaded file at path. Besides the path as
+// given, it matches the same file on disk reached through symlinks or, on
+// case-insensitive filesystems, spelled with different case.
+func (g *Graph) lookup(path string) (string, bool) {
+	path = filepath.Clean(path)
+	if _, ok := g.files[path]; ok {
+		return path, true
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	canonical := loader.CanonicalPath(path)
+	if key, ok := g.canonical[canonical]; ok {
+		return key, true
+	}
+	if key, ok := g.folded[strings.ToLower(canonical)]; ok {
+		if other, err := os.Stat(key); err == nil && os.SameFile(info, other) {
+			return key, true
+		}
+	}
+	return "", false
 }
 
 func (g *Graph) extractImports(file *loader.ProtoFile) []string {
@@ -39,71 +95,88 @@ func (g *Graph) extractImports(file *loader.ProtoFile) []string {
 	return imports
 }
 
-func (g *Graph) ResolveImports(baseDir string) error {
+// ResolveImports resolves every import against the loaded files. Include
+// paths are searched first, in order, like protoc's -I flags. Imports that
+// are not loaded but exist on disk below an include path are recorded as
+// siblings; everything else is external.
+func (g *Graph) ResolveImports(baseDir string, includePaths []string) error {
 	for path, imports := range g.imports {
+		resolved := make(map[string]string, len(imports))
 		for _, imp := range imports {
-			resolved := g.resolveImportPath(filepath.Dir(path), imp, baseDir)
-			if _, exists := g.files[resolved]; !exists {
-				g.external[imp] = true
+			if target, ok := g.resolveImportPath(filepath.Dir(path), imp, baseDir, includePaths); ok {
+				resolved[imp] = target
+				continue
+			}
+
+			if sibling, ok := findOnDisk(imp, includePaths); ok {
+				g.siblings[imp] = sibling
+				continue
 			}
+			g.external[imp] = true
 		}
+		g.resolved[path] = resolved
 	}
 	return nil
 }
 
-func (g *Graph) resolveImportPath(currentDir, importPath, baseDir string) string {
+func (g *Graph) resolveImportPath(currentDir, importPath, baseDir string, includePaths []string) (string, bool) {
 	if filepath.IsAbs(importPath) {
-		return importPath
+		return g.lookup(importPath)
 	}
 
-	candidates := []string{
+	var candidates []string
+	for _, root := range includePaths {
+		candidates = append(candidates, filepath.Join(root, importPath))
+	}
+	candidates = append(candidates,
 		filepath.Join(currentDir, importPath),
 		filepath.Join(baseDir, importPath),
 		importPath,
-	}
+	)
 
 	for _, candidate := range candidates {
-		if _, exists := g.files[candidate]; exists {
-			return candidate
+		if key, ok := g.lookup(candidate); ok {
+			return key, true
 		}
 	}
 
-	return importPath
+	return "", false
 }
 
-func (g *Graph) UpdateImports(relocations map[string]string) map[string][]ImportUpdate {
-	updates := make(map[string][]ImportUpdate)
-
-	for filePath, file := range g.files {
-		var fileUpdates []ImportUpdate
-
-		proto.Walk(file.Proto,
-			proto.WithImport(func(i *proto.Import) {
-				newPath := g.applyRelocations(i.Filename, relocations)
-				if newPath != i.Filename {
-					fileUpdates = append(fileUpdates, ImportUpdate{
-						OldPath: i.Filename,
-						NewPath: newPath,
-					})
-				}
-			}),
-		)
-
-		if len(fileUpdates) > 0 {
-			updates[filePath] = fileUpdates
+func findOnDisk(importPath string, includePaths []string) (string, bool) {
+	for _, root := range includePaths {
+		candidate := filepath.Join(root, importPath)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
 		}
 	}
-
-	return updates
+	return "", false
 }
 
-func (g *Graph) applyRelocations(importPath string, relocations map[string]string) string {
-	for oldPrefix, newPrefix := range relocations {
-		if strings.HasPrefix(importPath, oldPrefix) {
-			return strings.Replace(importPath, oldPrefix, newPrefix, 1)
+// UpdateImports returns, per file, the imports that newPath rewrites. newPath
+// is called with the importing file, the import as written and the loaded
+// file the import resolves to, or "" if it does not resolve. Files are
+// visited in path order. Call ResolveImports first.
+func (g *Graph) UpdateImports(newPath func(importer, importPath, target string) string) map[string][]ImportUpdate {
+	paths := make([]string, 0, len(g.files))
+	for path := range g.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	updates := make(map[string][]ImportUpdate)
+	for _, path := range paths {
+		for _, importPath := range g.imports[path] {
+			target := g.resolved[path][importPath]
+			if updated := newPath(path, importPath, target); updated != importPath {
+				updates[path] = append(updates[path], ImportUpdate{
+					OldPath: importPath,
+					NewPath: updated,
+				})
+			}
 		}
 	}
-	return importPath
+	return updates
 }
 
 func (g *Graph) GetExternalImports() []string {
@@ -114,6 +187,18 @@ func (g *Graph) GetExternalImports() []string {
 	return externals
 }
 
+// GetSiblingImports returns imports that are not part of the loaded files but
+// were found below an include path, mapped to their location on disk.
+func (g *Graph) GetSiblingImports() map[string]string {
+	return g.siblings
+}
+
+// ResolvedImport returns the loaded file that an import of path refers to.
+func (g *Graph) ResolvedImport(path, importPath string) (string, bool) {
+	target, ok := g.resolved[path][importPath]
+	return target, ok
+}
+
 func (g *Graph) GetFiles() map[string]*loader.ProtoFile {
 	return g.files
 }
@@ -123,20 +208,56 @@ type ImportUpdate struct {
 	NewPath string
 }
 
+// Relocations maps import path prefixes to their new location.
 type Relocations struct {
 	PathMap map[string]string
 }
 
+// BuildRelocations maps source to target and each from/to pair in rules.
+// Later pairs win over earlier ones with the same prefix.
 func BuildRelocations(source, target string, rules []string) *Relocations {
 	r := &Relocations{
 		PathMap: make(map[string]string),
 	}
 
-	r.PathMap[source] = target
+	r.PathMap[cleanPrefix(source)] = cleanPrefix(target)
 
 	for i := 0; i < len(rules)-1; i += 2 {
-		r.PathMap[rules[i]] = rules[i+1]
+		r.PathMap[cleanPrefix(rules[i])] = cleanPrefix(rules[i+1])
 	}
 
 	return r
 }
+
+func cleanPrefix(p string) string {
+	return path.Clean(filepath.ToSlash(p))
+}
+
+// Match returns the longest prefix of p, in whole path segments, that has a
+// relocation.
+func (r *Relocations) Match(p string) (string, bool) {
+	longest, found := "", false
+	for from := range r.PathMap {
+		if from == "." || (found && len(from) <= len(longest)) {
+			continue
+		}
+		if p == from || strings.HasPrefix(p, from+"/") {
+			longest, found = from, true
+		}
+	}
+	return longest, found
+}
+
+// Relocate maps p by its longest matching prefix.
+func (r *Relocations) Relocate(p string) (string, bool) {
+	from, ok := r.Match(p)
+	if !ok {
+		return p, false
+	}
+	to := r.PathMap[from]
+	rest := strings.TrimPrefix(p, from)
+	if to == "." {
+		return strings.TrimPrefix(rest, "/"), true
+	}
+	return to + rest, true
+}
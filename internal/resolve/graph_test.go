@@ -1,6 +1,9 @@
 package resolve
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/emicklei/proto"
@@ -31,60 +34,165 @@ func TestGraphAddFile(t *testing.T) {
 	}
 }
 
-func TestUpdateImports(t *testing.T) {
-	g := NewGraph()
+func TestBuildRelocations(t *testing.T) {
+	r := BuildRelocations("proto/old", "proto/new", []string{"old/v1", "new/v1", "old/v2", "new/v2"})
 
+	expected := map[string]string{
+		"proto/old": "proto/new",
+		"old/v1":    "new/v1",
+		"old/v2":    "new/v2",
+	}
+
+	if len(r.PathMap) != len(expected) {
+		t.Errorf("Expected %d relocations, got %d", len(expected), len(r.PathMap))
+	}
+
+	for k, v := range expected {
+		if r.PathMap[k] != v {
+			t.Errorf("Expected %s -> %s, got %s", k, v, r.PathMap[k])
+		}
+	}
+}
+
+func TestResolveImportsWithIncludePaths(t *testing.T) {
+	root := t.TempDir()
+	siblingPath := filepath.Join(root, "shared", "v1", "money.proto")
+	if err := os.MkdirAll(filepath.Dir(siblingPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(siblingPath, []byte(`syntax = "proto3";`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGraph()
+	g.AddFile(&loader.ProtoFile{
+		Path:  filepath.Join(root, "old", "v1", "types.proto"),
+		Proto: &proto.Proto{},
+	})
 	file := &loader.ProtoFile{
-		Path: "test.proto",
+		Path: filepath.Join(root, "old", "v1", "service.proto"),
 		Proto: &proto.Proto{
 			Elements: []proto.Visitee{
 				&proto.Import{Filename: "old/v1/types.proto"},
+				&proto.Import{Filename: "shared/v1/money.proto"},
+				&proto.Import{Filename: "google/protobuf/timestamp.proto"},
 			},
 		},
 	}
-
 	g.AddFile(file)
 
-	relocations := map[string]string{
-		"old/v1": "new/v1",
+	if err := g.ResolveImports(filepath.Join(root, "old"), []string{root}); err != nil {
+		t.Fatalf("ResolveImports() error = %v", err)
 	}
 
-	updates := g.UpdateImports(relocations)
+	if target, ok := g.ResolvedImport(file.Path, "old/v1/types.proto"); !ok || target != filepath.Join(root, "old", "v1", "types.proto") {
+		t.Errorf("Expected old/v1/types.proto to resolve to the loaded file, got %q", target)
+	}
+	if got := g.GetSiblingImports()["shared/v1/money.proto"]; got != siblingPath {
+		t.Errorf("Expected sibling import at %s, got %q", siblingPath, got)
+	}
+	externals := g.GetExternalImports()
+	if len(externals) != 1 || externals[0] != "google/protobuf/timestamp.proto" {
+		t.Errorf("Expected only the well-known import to be external, got %v", externals)
+	}
+}
 
-	if len(updates) != 1 {
-		t.Fatalf("Expected 1 file with updates, got %d", len(updates))
+func TestResolveImportsThroughSymlink(t *testing.T) {
+	root := t.TempDir()
+	typesPath := filepath.Join(root, "proto", "acme", "types.proto")
+	if err := os.MkdirAll(filepath.Dir(typesPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(typesPath, []byte(`syntax = "proto3";`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	linkedRoot := filepath.Join(root, "include")
+	if err := os.Symlink(filepath.Join(root, "proto"), linkedRoot); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
 	}
 
-	fileUpdates := updates[file.Path]
-	if len(fileUpdates) != 1 {
-		t.Fatalf("Expected 1 update, got %d", len(fileUpdates))
+	g := NewGraph()
+	g.AddFile(&loader.ProtoFile{Path: typesPath, Proto: &proto.Proto{}})
+	file := &loader.ProtoFile{
+		Path: filepath.Join(root, "proto", "acme", "service.proto"),
+		Proto: &proto.Proto{
+			Elements: []proto.Visitee{&proto.Import{Filename: "acme/types.proto"}},
+		},
+	}
+	g.AddFile(file)
+
+	if err := g.ResolveImports(filepath.Join(root, "proto"), []string{linkedRoot}); err != nil {
+		t.Fatalf("ResolveImports() error = %v", err)
 	}
 
-	update := fileUpdates[0]
-	if update.OldPath != "old/v1/types.proto" {
-		t.Errorf("Expected old path 'old/v1/types.proto', got %s", update.OldPath)
+	if target, ok := g.ResolvedImport(file.Path, "acme/types.proto"); !ok || target != typesPath {
+		t.Errorf("Expected acme/types.proto to resolve to %s through the linked include path, got %q", typesPath, target)
 	}
-	if update.NewPath != "new/v1/types.proto" {
-		t.Errorf("Expected new path 'new/v1/types.proto', got %s", update.NewPath)
+	if siblings := g.GetSiblingImports(); len(siblings) != 0 {
+		t.Errorf("Expected no siblings, got %v", siblings)
 	}
 }
 
-func TestBuildRelocations(t *testing.T) {
-	r := BuildRelocations("proto/old", "proto/new", []string{"old/v1", "new/v1", "old/v2", "new/v2"})
+func TestRelocate(t *testing.T) {
+	r := BuildRelocations("proto/old", "proto/new", []string{"old", "new", "old/v1/internal", "new/v1/private", "gone", "."})
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{path: "old/v1/a.proto", want: "new/v1/a.proto", wantOK: true},
+		{path: "old/v1/internal/b.proto", want: "new/v1/private/b.proto", wantOK: true},
+		{path: "old/v1/internalx/c.proto", want: "new/v1/internalx/c.proto", wantOK: true},
+		{path: "proto/old/v1/a.proto", want: "proto/new/v1/a.proto", wantOK: true},
+		{path: "gone/x.proto", want: "x.proto", wantOK: true},
+		{path: "older/v1/a.proto", want: "older/v1/a.proto"},
+	}
 
-	expected := map[string]string{
-		"proto/old": "proto/new",
-		"old/v1":    "new/v1",
-		"old/v2":    "new/v2",
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := r.Relocate(tt.path)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Relocate(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+			}
+		})
 	}
+}
 
-	if len(r.PathMap) != len(expected) {
-		t.Errorf("Expected %d relocations, got %d", len(expected), len(r.PathMap))
+func TestUpdateImports(t *testing.T) {
+	g := NewGraph()
+	g.AddFile(&loader.ProtoFile{
+		Path: "test.proto",
+		Proto: &proto.Proto{
+			Elements: []proto.Visitee{
+				&proto.Import{Filename: "old/v1/types.proto"},
+				&proto.Import{Filename: "other.proto"},
+			},
+		},
+	})
+	g.AddFile(&loader.ProtoFile{Path: "old/v1/types.proto", Proto: &proto.Proto{}})
+	if err := g.ResolveImports(".", nil); err != nil {
+		t.Fatal(err)
 	}
 
-	for k, v := range expected {
-		if r.PathMap[k] != v {
-			t.Errorf("Expected %s -> %s, got %s", k, v, r.PathMap[k])
+	r := &Relocations{PathMap: map[string]string{"old/v1": "new/v1"}}
+	var unresolved []string
+	updates := g.UpdateImports(func(importer, importPath, target string) string {
+		if target == "" {
+			unresolved = append(unresolved, importPath)
+			return importPath
 		}
+		newPath, _ := r.Relocate(target)
+		return newPath
+	})
+
+	want := map[string][]ImportUpdate{
+		"test.proto": {{OldPath: "old/v1/types.proto", NewPath: "new/v1/types.proto"}},
+	}
+	if !reflect.DeepEqual(updates, want) {
+		t.Errorf("UpdateImports() = %v, want %v", updates, want)
+	}
+	if !reflect.DeepEqual(unresolved, []string{"other.proto"}) {
+		t.Errorf("unresolved = %v, want [other.proto]", unresolved)
 	}
 }
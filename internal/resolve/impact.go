@@ -0,0 +1,146 @@
+package resolve
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// Impact is everything a rename of a symbol or package would touch.
+type Impact struct {
+	Name string     `json:"name"`
+	Kind SymbolKind `json:"kind"`
+	// Definitions are the definitions of the name and of everything nested
+	// below it, e.g. the messages of a package or the rpcs of a service.
+	Definitions []Symbol    `json:"definitions"`
+	References  []Reference `json:"references"`
+	// Importers are the files importing a file that defines the name.
+	Importers []string `json:"importers,omitempty"`
+	// Options are the language options derived from a renamed package.
+	Options []OptionUse `json:"options,omitempty"`
+	Files   []string    `json:"files"`
+}
+
+type OptionUse struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// DirCount is the number of touched files in a directory.
+type DirCount struct {
+	Dir   string `json:"dir"`
+	Files int    `json:"files"`
+}
+
+// languageOptions are the file options whose value follows the package.
+var languageOptions = map[string]bool{
+	"go_package":        true,
+	"java_package":      true,
+	"csharp_namespace":  true,
+	"php_namespace":     true,
+	"ruby_package":      true,
+	"objc_class_prefix": true,
+	"swift_prefix":      true,
+}
+
+// Impact returns what renaming name, a fully-qualified symbol or package,
+// would touch. A package is renamed with its contents, so its files'
+// importers and language options are included as well.
+func (g *Graph) Impact(name string) (*Impact, error) {
+	name = strings.TrimPrefix(name, ".")
+	defs := g.Lookup(name)
+	if len(defs) == 0 {
+		return nil, fmt.Errorf("no symbol or package named %q", name)
+	}
+	impact := &Impact{Name: name, Kind: defs[0].Kind}
+
+	within := func(symbol string) bool {
+		return symbol == name || strings.HasPrefix(symbol, name+".")
+	}
+	for _, symbol := range g.Symbols() {
+		if within(symbol.Name) {
+			impact.Definitions = append(impact.Definitions, symbol)
+		}
+	}
+	for _, ref := range g.AllReferences() {
+		if within(ref.Symbol) {
+			impact.References = append(impact.References, ref)
+		}
+	}
+
+	files := make(map[string]bool)
+	defining := make(map[string]bool)
+	for _, def := range impact.Definitions {
+		files[def.File] = true
+		defining[def.File] = true
+	}
+	for _, ref := range impact.References {
+		files[ref.File] = true
+	}
+
+	if impact.Kind == KindPackage {
+		for path := range g.files {
+			for _, target := range g.resolved[path] {
+				if defining[target] && !defining[path] {
+					impact.Importers = append(impact.Importers, path)
+					files[path] = true
+					break
+				}
+			}
+		}
+		sort.Strings(impact.Importers)
+
+		for _, def := range defs {
+			impact.Options = append(impact.Options, fileOptions(def.File, g.files[def.File].Proto)...)
+		}
+	}
+
+	for path := range files {
+		impact.Files = append(impact.Files, path)
+	}
+	sort.Strings(impact.Files)
+	return impact, nil
+}
+
+func fileOptions(path string, definition *proto.Proto) []OptionUse {
+	var options []OptionUse
+	for _, element := range definition.Elements {
+		if o, ok := element.(*proto.Option); ok && languageOptions[o.Name] {
+			options = append(options, OptionUse{
+				Name:   o.Name,
+				Value:  o.Constant.Source,
+				File:   path,
+				Line:   o.Position.Line,
+				Column: o.Position.Column,
+			})
+		}
+	}
+	return options
+}
+
+// Dirs counts the touched files per directory, relative to baseDir.
+func (i *Impact) Dirs(baseDir string) []DirCount {
+	counts := make(map[string]int)
+	for _, file := range i.Files {
+		dir := filepath.Dir(file)
+		if rel, err := filepath.Rel(baseDir, dir); err == nil {
+			dir = rel
+		}
+		counts[filepath.ToSlash(dir)]++
+	}
+
+	dirs := make([]DirCount, 0, len(counts))
+	for dir, n := range counts {
+		dirs = append(dirs, DirCount{Dir: dir, Files: n})
+	}
+	sort.Slice(dirs, func(a, b int) bool {
+		return dirs[a].Dir < dirs[b].Dir
+	})
+	return dirs
+}
@@ -0,0 +1,81 @@
+package resolve
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImpact(t *testing.T) {
+	g := newSymbolGraph(t, map[string]string{
+		"old/v1/types.proto": `syntax = "proto3";
+package old.v1;
+option go_package = "example.com/old/v1";
+
+message Item {
+  message Detail {}
+  Detail detail = 1;
+}
+`,
+		"api/v1/service.proto": `syntax = "proto3";
+package api.v1;
+import "old/v1/types.proto";
+
+service Api {
+  rpc Get(old.v1.Item) returns (old.v1.Item.Detail);
+}
+`,
+		"api/v1/unrelated.proto": `syntax = "proto3";
+package api.v1;
+message Other {}
+`,
+	})
+	if err := g.ResolveImports(".", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("package", func(t *testing.T) {
+		impact, err := g.Impact("old.v1")
+		if err != nil {
+			t.Fatalf("Impact() error = %v", err)
+		}
+		if impact.Kind != KindPackage || len(impact.Definitions) != 3 || len(impact.References) != 3 {
+			t.Errorf("Impact() = %+v", impact)
+		}
+		if want := []string{"api/v1/service.proto"}; !reflect.DeepEqual(impact.Importers, want) {
+			t.Errorf("Importers = %v, want %v", impact.Importers, want)
+		}
+		if len(impact.Options) != 1 || impact.Options[0].Value != "example.com/old/v1" {
+			t.Errorf("Options = %+v", impact.Options)
+		}
+		wantDirs := []DirCount{{Dir: "api/v1", Files: 1}, {Dir: "old/v1", Files: 1}}
+		if got := impact.Dirs("."); !reflect.DeepEqual(got, wantDirs) {
+			t.Errorf("Dirs() = %v, want %v", got, wantDirs)
+		}
+	})
+
+	t.Run("nested message", func(t *testing.T) {
+		impact, err := g.Impact(".old.v1.Item.Detail")
+		if err != nil {
+			t.Fatalf("Impact() error = %v", err)
+		}
+		var refs []string
+		for _, ref := range impact.References {
+			refs = append(refs, ref.Usage+" "+ref.Context)
+		}
+		if want := []string{"response api.v1.Api.Get", "field old.v1.Item"}; !reflect.DeepEqual(refs, want) {
+			t.Errorf("references = %v, want %v", refs, want)
+		}
+		if want := []string{"api/v1/service.proto", "old/v1/types.proto"}; !reflect.DeepEqual(impact.Files, want) {
+			t.Errorf("Files = %v, want %v", impact.Files, want)
+		}
+		if impact.Importers != nil || impact.Options != nil {
+			t.Errorf("expected no importers or options for a message, got %+v", impact)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := g.Impact("old.v1.Missing"); err == nil {
+			t.Error("expected an error for an unknown symbol")
+		}
+	})
+}
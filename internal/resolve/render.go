@@ -0,0 +1,194 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+type NodeKind string
+
+const (
+	// NodeFile is a loaded file, NodeSibling an import found below an
+	// include path and NodeExternal an import found nowhere.
+	NodeFile     NodeKind = "file"
+	NodeSibling  NodeKind = "sibling"
+	NodeExternal NodeKind = "external"
+)
+
+type Node struct {
+	ID      string   `json:"id"`
+	Kind    NodeKind `json:"kind"`
+	Package string   `json:"package,omitempty"`
+	Changed bool     `json:"changed,omitempty"`
+}
+
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ImportGraph is the import graph in a form ready for rendering.
+type ImportGraph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+type ExportOptions struct {
+	// BaseDir is trimmed from the IDs of loaded files.
+	BaseDir string
+	// Changed marks loaded files, by path, as changed.
+	Changed map[string]bool
+	// External includes sibling and external imports as nodes.
+	External bool
+}
+
+// Export returns the import graph of the loaded files. Call ResolveImports
+// first.
+func (g *Graph) Export(opts ExportOptions) *ImportGraph {
+	id := func(path string) string {
+		if rel, err := filepath.Rel(opts.BaseDir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+		return filepath.ToSlash(path)
+	}
+
+	nodes := make(map[string]Node)
+	var edges []Edge
+	for path, file := range g.files {
+		from := id(path)
+		nodes[from] = Node{
+			ID:      from,
+			Kind:    NodeFile,
+			Package: filePackage(file.Proto),
+			Changed: opts.Changed[path],
+		}
+
+		for _, imp := range g.imports[path] {
+			if target, ok := g.resolved[path][imp]; ok {
+				edges = append(edges, Edge{From: from, To: id(target)})
+				continue
+			}
+			if !opts.External {
+				continue
+			}
+			kind := NodeExternal
+			if _, ok := g.siblings[imp]; ok {
+				kind = NodeSibling
+			}
+			nodes[imp] = Node{ID: imp, Kind: kind}
+			edges = append(edges, Edge{From: from, To: imp})
+		}
+	}
+
+	out := &ImportGraph{Nodes: make([]Node, 0, len(nodes)), Edges: edges}
+	for _, node := range nodes {
+		out.Nodes = append(out.Nodes, node)
+	}
+	sort.Slice(out.Nodes, func(i, j int) bool {
+		return out.Nodes[i].ID < out.Nodes[j].ID
+	})
+	sort.Slice(out.Edges, func(i, j int) bool {
+		if out.Edges[i].From != out.Edges[j].From {
+			return out.Edges[i].From < out.Edges[j].From
+		}
+		return out.Edges[i].To < out.Edges[j].To
+	})
+	return out
+}
+
+func filePackage(definition *proto.Proto) string {
+	var name string
+	if definition != nil {
+		proto.Walk(definition, proto.WithPackage(func(p *proto.Package) {
+			name = p.Name
+		}))
+	}
+	return name
+}
+
+// Render writes the graph as "dot", "mermaid" or "json".
+func (ig *ImportGraph) Render(w io.Writer, format string) error {
+	switch format {
+	case "dot":
+		return ig.writeDOT(w)
+	case "mermaid":
+		return ig.writeMermaid(w)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(ig)
+	default:
+		return fmt.Errorf("unknown graph format %q (want dot, mermaid or json)", format)
+	}
+}
+
+func (ig *ImportGraph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph imports {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, node := range ig.Nodes {
+		fmt.Fprintf(&b, "  %q", node.ID)
+		switch {
+		case node.Changed:
+			b.WriteString(` [style=filled, fillcolor="#fdd49e"]`)
+		case node.Kind == NodeSibling:
+			b.WriteString(` [style=dashed]`)
+		case node.Kind == NodeExternal:
+			b.WriteString(` [style="dashed,filled", fillcolor="#eeeeee"]`)
+		}
+		b.WriteString(";\n")
+	}
+	for _, edge := range ig.Edges {
+		fmt.Fprintf(&b, "  %q -> %q;\n", edge.From, edge.To)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (ig *ImportGraph) writeMermaid(w io.Writer) error {
+	// Mermaid IDs cannot contain path characters, so nodes are numbered and
+	// labelled with their path.
+	ids := make(map[string]string, len(ig.Nodes))
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for i, node := range ig.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[node.ID], strings.ReplaceAll(node.ID, `"`, "#quot;"))
+	}
+	for _, edge := range ig.Edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", ids[edge.From], ids[edge.To])
+	}
+
+	classes := map[string][]string{}
+	for _, node := range ig.Nodes {
+		switch {
+		case node.Changed:
+			classes["changed"] = append(classes["changed"], ids[node.ID])
+		case node.Kind == NodeSibling:
+			classes["sibling"] = append(classes["sibling"], ids[node.ID])
+		case node.Kind == NodeExternal:
+			classes["external"] = append(classes["external"], ids[node.ID])
+		}
+	}
+	for _, class := range []struct{ name, style string }{
+		{"changed", "fill:#fdd49e"},
+		{"sibling", "stroke-dasharray:5 5"},
+		{"external", "fill:#eeeeee,stroke-dasharray:5 5"},
+	} {
+		if len(classes[class.name]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  classDef %s %s\n", class.name, class.style)
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(classes[class.name], ","), class.name)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
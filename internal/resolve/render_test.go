@@ -0,0 +1,87 @@
+package resolve
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/emicklei/proto"
+	"github.com/jackchuka/proto-migrate/internal/loader"
+)
+
+func TestExportRender(t *testing.T) {
+	root := t.TempDir()
+	g := NewGraph()
+	g.AddFile(&loader.ProtoFile{
+		Path:  filepath.Join(root, "v1", "types.proto"),
+		Proto: &proto.Proto{Elements: []proto.Visitee{&proto.Package{Name: "old.v1"}}},
+	})
+	g.AddFile(&loader.ProtoFile{
+		Path: filepath.Join(root, "v1", "service.proto"),
+		Proto: &proto.Proto{
+			Elements: []proto.Visitee{
+				&proto.Package{Name: "old.v1"},
+				&proto.Import{Filename: "v1/types.proto"},
+				&proto.Import{Filename: "google/protobuf/empty.proto"},
+			},
+		},
+	})
+	if err := g.ResolveImports(root, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	graph := g.Export(ExportOptions{
+		BaseDir:  root,
+		Changed:  map[string]bool{filepath.Join(root, "v1", "types.proto"): true},
+		External: true,
+	})
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "dot",
+			want: `digraph imports {
+  rankdir=LR;
+  node [shape=box];
+  "google/protobuf/empty.proto" [style="dashed,filled", fillcolor="#eeeeee"];
+  "v1/service.proto";
+  "v1/types.proto" [style=filled, fillcolor="#fdd49e"];
+  "v1/service.proto" -> "google/protobuf/empty.proto";
+  "v1/service.proto" -> "v1/types.proto";
+}
+`,
+		},
+		{
+			format: "mermaid",
+			want: `graph LR
+  n0["google/protobuf/empty.proto"]
+  n1["v1/service.proto"]
+  n2["v1/types.proto"]
+  n1 --> n0
+  n1 --> n2
+  classDef changed fill:#fdd49e
+  class n2 changed
+  classDef external fill:#eeeeee,stroke-dasharray:5 5
+  class n0 external
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := graph.Render(&buf, tt.format); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+
+	if err := graph.Render(&bytes.Buffer{}, "svg"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,315 @@
+package resolve
+
+import (
+	"sort"
+	"strings"
+	"text/scanner"
+
+	"github.com/emicklei/proto"
+	"github.com/jackchuka/proto-migrate/internal/loader"
+)
+
+type SymbolKind string
+
+const (
+	KindPackage   SymbolKind = "package"
+	KindMessage   SymbolKind = "message"
+	KindEnum      SymbolKind = "enum"
+	KindService   SymbolKind = "service"
+	KindRPC       SymbolKind = "rpc"
+	KindExtension SymbolKind = "extension"
+)
+
+// Symbol is a definition of a fully-qualified name. A package has one
+// definition per file that declares it.
+type Symbol struct {
+	Name   string     `json:"name"`
+	Kind   SymbolKind `json:"kind"`
+	File   string     `json:"file"`
+	Line   int        `json:"line"`
+	Column int        `json:"column"`
+}
+
+// Reference is a use of a name: a field type, an rpc request or response,
+// the message an extend block extends, or the extension of a custom option.
+type Reference struct {
+	// Symbol is the fully-qualified name the reference resolves to. When it
+	// does not resolve against the loaded files, it is the name as written,
+	// without a leading dot, and Resolved is false.
+	Symbol   string `json:"symbol"`
+	Resolved bool   `json:"resolved"`
+	// Name is the name as written, without the parentheses of an option.
+	Name string `json:"name"`
+	// Context is the definition the reference appears in, e.g. the message
+	// holding the field or the rpc.
+	Context string `json:"context"`
+	Usage   string `json:"usage"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+// typeUse is a type or extension name as written, before it is resolved against the
+// symbol table.
+type typeUse struct {
+	name  string
+	scope string
+	ref   Reference
+}
+
+// IsScalar reports whether name is one of the protobuf scalar types.
+func IsScalar(name string) bool {
+	return scalarTypes[name]
+}
+
+var scalarTypes = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true,
+	"uint64": true, "sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true, "bool": true, "string": true, "bytes": true,
+}
+
+// addSymbols records the definitions and the type and option uses of file.
+func (g *Graph) addSymbols(path string, file *loader.ProtoFile) {
+	if file.Proto == nil {
+		return
+	}
+	var pkg string
+	for _, element := range file.Proto.Elements {
+		if p, ok := element.(*proto.Package); ok {
+			pkg = p.Name
+			g.define(KindPackage, pkg, path, p.Position)
+		}
+	}
+	for prefix := pkg; prefix != ""; prefix = ParentScope(prefix) {
+		g.packages[prefix] = true
+	}
+
+	c := &symbolCollector{graph: g, path: path}
+	c.elements(pkg, file.Proto.Elements)
+	g.references = nil
+}
+
+type symbolCollector struct {
+	graph *Graph
+	path  string
+}
+
+func (c *symbolCollector) elements(scope string, elements []proto.Visitee) {
+	for _, element := range elements {
+		switch e := element.(type) {
+		case *proto.Message:
+			if e.IsExtend {
+				c.use(e.Name, scope, scope, "extend", e.Position)
+				c.extensions(scope, e)
+				continue
+			}
+			name := Qualify(scope, e.Name)
+			c.graph.define(KindMessage, name, c.path, e.Position)
+			c.elements(name, e.Elements)
+		case *proto.Group:
+			name := Qualify(scope, e.Name)
+			c.graph.define(KindMessage, name, c.path, e.Position)
+			c.elements(name, e.Elements)
+		case *proto.Enum:
+			name := Qualify(scope, e.Name)
+			c.graph.define(KindEnum, name, c.path, e.Position)
+			c.elements(name, e.Elements)
+		case *proto.EnumField:
+			c.elements(scope, e.Elements)
+		case *proto.Service:
+			name := Qualify(scope, e.Name)
+			c.graph.define(KindService, name, c.path, e.Position)
+			c.elements(name, e.Elements)
+		case *proto.RPC:
+			name := Qualify(scope, e.Name)
+			c.graph.define(KindRPC, name, c.path, e.Position)
+			// Types in an rpc resolve from the package, like the service.
+			c.use(e.RequestType, ParentScope(scope), name, "request", e.Position)
+			c.use(e.ReturnsType, ParentScope(scope), name, "response", e.Position)
+			c.elements(name, e.Elements)
+		case *proto.Oneof:
+			c.elements(scope, e.Elements)
+		case *proto.NormalField:
+			c.use(e.Type, scope, scope, "field", e.Position)
+			c.options(scope, scope, e.Options)
+		case *proto.OneOfField:
+			c.use(e.Type, scope, scope, "field", e.Position)
+			c.options(scope, scope, e.Options)
+		case *proto.MapField:
+			c.use(e.Type, scope, scope, "field", e.Position)
+			c.options(scope, scope, e.Options)
+		case *proto.Option:
+			c.options(scope, scope, []*proto.Option{e})
+		}
+	}
+}
+
+// extensions defines the fields of an extend block, which are named in the
+// scope the block appears in.
+func (c *symbolCollector) extensions(scope string, extend *proto.Message) {
+	for _, element := range extend.Elements {
+		switch e := element.(type) {
+		case *proto.NormalField:
+			c.graph.define(KindExtension, Qualify(scope, e.Name), c.path, e.Position)
+			c.use(e.Type, scope, Qualify(scope, e.Name), "field", e.Position)
+			c.options(scope, Qualify(scope, e.Name), e.Options)
+		case *proto.Group:
+			c.graph.define(KindExtension, Qualify(scope, e.Name), c.path, e.Position)
+		}
+	}
+}
+
+// options records the extension named by each custom option, such as
+// (acme.v1.owner) in (acme.v1.owner).team, as a use in scope.
+func (c *symbolCollector) options(scope, context string, options []*proto.Option) {
+	for _, o := range options {
+		inner, ok := strings.CutPrefix(o.Name, "(")
+		if !ok {
+			continue
+		}
+		inner, _, _ = strings.Cut(inner, ")")
+		c.use(inner, scope, context, "option", o.Position)
+	}
+}
+
+func (c *symbolCollector) use(name, scope, context, usage string, pos scanner.Position) {
+	if name == "" || IsScalar(name) {
+		return
+	}
+	c.graph.uses = append(c.graph.uses, typeUse{
+		name:  name,
+		scope: scope,
+		ref: Reference{
+			Name:    name,
+			Context: context,
+			Usage:   usage,
+			File:    c.path,
+			Line:    pos.Line,
+			Column:  pos.Column,
+		},
+	})
+}
+
+func (g *Graph) define(kind SymbolKind, name, path string, pos scanner.Position) {
+	g.symbols[name] = append(g.symbols[name], Symbol{
+		Name:   name,
+		Kind:   kind,
+		File:   path,
+		Line:   pos.Line,
+		Column: pos.Column,
+	})
+}
+
+// ResolveType resolves a type name written in scope the way protoc does: a
+// leading dot makes it fully-qualified, otherwise the first component is
+// looked up from the innermost scope outwards and the rest of the name must
+// exist below the first match.
+func (g *Graph) ResolveType(name, scope string) (string, bool) {
+	if strings.HasPrefix(name, ".") {
+		name = name[1:]
+		_, ok := g.symbols[name]
+		return name, ok
+	}
+
+	first, _, _ := strings.Cut(name, ".")
+	for s := scope; ; s = ParentScope(s) {
+		candidate := Qualify(s, first)
+		if g.known(candidate) {
+			full := Qualify(s, name)
+			_, ok := g.symbols[full]
+			return full, ok
+		}
+		if s == "" {
+			return name, false
+		}
+	}
+}
+
+func (g *Graph) known(name string) bool {
+	_, ok := g.symbols[name]
+	return ok || g.packages[name]
+}
+
+// buildReferences resolves every recorded use and indexes the result by
+// symbol.
+func (g *Graph) buildReferences() {
+	g.references = make(map[string][]Reference)
+	for _, u := range g.uses {
+		ref := u.ref
+		ref.Symbol, ref.Resolved = g.ResolveType(u.name, u.scope)
+		g.references[ref.Symbol] = append(g.references[ref.Symbol], ref)
+	}
+	for _, refs := range g.references {
+		sortReferences(refs)
+	}
+}
+
+// Symbols returns every definition, sorted by name and then file.
+func (g *Graph) Symbols() []Symbol {
+	var symbols []Symbol
+	for _, defs := range g.symbols {
+		symbols = append(symbols, defs...)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Name != symbols[j].Name {
+			return symbols[i].Name < symbols[j].Name
+		}
+		return symbols[i].File < symbols[j].File
+	})
+	return symbols
+}
+
+// Lookup returns the definitions of a fully-qualified name, without a leading
+// dot.
+func (g *Graph) Lookup(name string) []Symbol {
+	return g.symbols[strings.TrimPrefix(name, ".")]
+}
+
+// References returns the uses of a fully-qualified name, sorted by position.
+func (g *Graph) References(name string) []Reference {
+	if g.references == nil {
+		g.buildReferences()
+	}
+	return g.references[strings.TrimPrefix(name, ".")]
+}
+
+// AllReferences returns every use, sorted by position.
+func (g *Graph) AllReferences() []Reference {
+	if g.references == nil {
+		g.buildReferences()
+	}
+	var refs []Reference
+	for _, r := range g.references {
+		refs = append(refs, r...)
+	}
+	sortReferences(refs)
+	return refs
+}
+
+func sortReferences(refs []Reference) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].File != refs[j].File {
+			return refs[i].File < refs[j].File
+		}
+		if refs[i].Line != refs[j].Line {
+			return refs[i].Line < refs[j].Line
+		}
+		return refs[i].Column < refs[j].Column
+	})
+}
+
+// Qualify returns name declared in scope as a fully-qualified name.
+func Qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// ParentScope returns the scope enclosing scope, or "" for a top-level one.
+func ParentScope(scope string) string {
+	if i := strings.LastIndex(scope, "."); i >= 0 {
+		return scope[:i]
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package resolve
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jackchuka/proto-migrate/internal/loader"
+)
+
+func newSymbolGraph(t *testing.T, files map[string]string) *Graph {
+	t.Helper()
+	g := NewGraph()
+	for path, content := range files {
+		ast, err := loader.Parse(path, content)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", path, err)
+		}
+		g.AddFile(&loader.ProtoFile{Path: path, Proto: ast, Content: content})
+	}
+	return g
+}
+
+func TestSymbols(t *testing.T) {
+	g := newSymbolGraph(t, map[string]string{
+		"types.proto": `syntax = "proto2";
+package old.v1;
+
+message Item {
+  message Detail {
+    enum Kind { KIND_UNSPECIFIED = 0; }
+  }
+  optional string name = 1;
+}
+
+extend Item {
+  optional string note = 100;
+}
+`,
+		"service.proto": `syntax = "proto3";
+package old.v1;
+
+service ItemService {
+  rpc GetItem(Item) returns (Item);
+}
+`,
+	})
+
+	var got []string
+	for _, s := range g.Symbols() {
+		got = append(got, string(s.Kind)+" "+s.Name+" "+s.File)
+	}
+	want := []string{
+		"package old.v1 service.proto",
+		"package old.v1 types.proto",
+		"message old.v1.Item types.proto",
+		"message old.v1.Item.Detail types.proto",
+		"enum old.v1.Item.Detail.Kind types.proto",
+		"service old.v1.ItemService service.proto",
+		"rpc old.v1.ItemService.GetItem service.proto",
+		"extension old.v1.note types.proto",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Symbols() =\n%v\nwant\n%v", got, want)
+	}
+
+	if defs := g.Lookup(".old.v1.Item.Detail"); len(defs) != 1 || defs[0].Line != 5 || defs[0].Column != 3 {
+		t.Errorf("Lookup(.old.v1.Item.Detail) = %+v", defs)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	g := newSymbolGraph(t, map[string]string{
+		"a.proto": `syntax = "proto3";
+package acme.old.v1;
+
+import "google/protobuf/timestamp.proto";
+
+message Item {
+  message Detail {}
+  Detail detail = 1;
+  old.v1.Item parent = 2;
+  .acme.old.v1.Item.Detail other = 3;
+  map<string, Item> children = 4;
+  oneof choice {
+    Detail picked = 5;
+  }
+  google.protobuf.Timestamp created = 6;
+}
+
+service ItemService {
+  rpc GetItem(Item) returns (stream Item.Detail);
+}
+`,
+	})
+
+	tests := []struct {
+		symbol string
+		want   []Reference
+	}{
+		{
+			symbol: "acme.old.v1.Item",
+			want: []Reference{
+				{Symbol: "acme.old.v1.Item", Resolved: true, Name: "old.v1.Item", Context: "acme.old.v1.Item", Usage: "field", File: "a.proto", Line: 9, Column: 3},
+				{Symbol: "acme.old.v1.Item", Resolved: true, Name: "Item", Context: "acme.old.v1.Item", Usage: "field", File: "a.proto", Line: 11, Column: 3},
+				{Symbol: "acme.old.v1.Item", Resolved: true, Name: "Item", Context: "acme.old.v1.ItemService.GetItem", Usage: "request", File: "a.proto", Line: 19, Column: 3},
+			},
+		},
+		{
+			symbol: "acme.old.v1.Item.Detail",
+			want: []Reference{
+				{Symbol: "acme.old.v1.Item.Detail", Resolved: true, Name: "Detail", Context: "acme.old.v1.Item", Usage: "field", File: "a.proto", Line: 8, Column: 3},
+				{Symbol: "acme.old.v1.Item.Detail", Resolved: true, Name: ".acme.old.v1.Item.Detail", Context: "acme.old.v1.Item", Usage: "field", File: "a.proto", Line: 10, Column: 3},
+				{Symbol: "acme.old.v1.Item.Detail", Resolved: true, Name: "Detail", Context: "acme.old.v1.Item", Usage: "field", File: "a.proto", Line: 13, Column: 5},
+				{Symbol: "acme.old.v1.Item.Detail", Resolved: true, Name: "Item.Detail", Context: "acme.old.v1.ItemService.GetItem", Usage: "response", File: "a.proto", Line: 19, Column: 3},
+			},
+		},
+		{
+			symbol: "google.protobuf.Timestamp",
+			want: []Reference{
+				{Symbol: "google.protobuf.Timestamp", Name: "google.protobuf.Timestamp", Context: "acme.old.v1.Item", Usage: "field", File: "a.proto", Line: 15, Column: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			if got := g.References(tt.symbol); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("References(%s) =\n%+v\nwant\n%+v", tt.symbol, got, tt.want)
+			}
+		})
+	}
+
+	if got := len(g.AllReferences()); got != 8 {
+		t.Errorf("AllReferences() returned %d references, want 8", got)
+	}
+}
+
+func TestOptionReferences(t *testing.T) {
+	g := newSymbolGraph(t, map[string]string{
+		"options.proto": `syntax = "proto3";
+package acme.v1;
+
+import "google/protobuf/descriptor.proto";
+
+extend google.protobuf.FileOptions {
+  string owner = 50000;
+}
+`,
+		"a.proto": `syntax = "proto3";
+package team.v1;
+
+option (acme.v1.owner) = "team";
+
+message Item {
+  option (.acme.v1.owner) = "items";
+  string id = 1 [(acme.v1.owner) = "ids"];
+  enum Kind {
+    KIND_UNSPECIFIED = 0 [(acme.v1.owner) = "kinds"];
+  }
+}
+
+service ItemService {
+  rpc GetItem(Item) returns (Item) {
+    option (acme.v1.owner).team = "rpcs";
+  }
+}
+`,
+	})
+
+	var got []string
+	for _, ref := range g.References("acme.v1.owner") {
+		if ref.Usage != "option" {
+			t.Errorf("reference %+v has usage %q, want option", ref, ref.Usage)
+		}
+		got = append(got, fmt.Sprintf("%s:%d %s in %s", ref.File, ref.Line, ref.Name, ref.Context))
+	}
+	want := []string{
+		"a.proto:4 acme.v1.owner in team.v1",
+		"a.proto:7 .acme.v1.owner in team.v1.Item",
+		"a.proto:8 acme.v1.owner in team.v1.Item",
+		"a.proto:10 acme.v1.owner in team.v1.Item.Kind",
+		"a.proto:16 acme.v1.owner in team.v1.ItemService.GetItem",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("References(acme.v1.owner) =\n%v\nwant\n%v", got, want)
+	}
+}
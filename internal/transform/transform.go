@@ -39,6 +39,9 @@ func init() {
 	RegisterRule("regexp", func(cfg config.Rule) Rule {
 		return &RegexpRule{Pattern: cfg.Pattern, Replace: cfg.Replace}
 	})
+	RegisterRule("relocate", func(cfg config.Rule) Rule {
+		return &RelocateRule{From: cfg.From, To: cfg.To}
+	})
 }
 
 func RegisterRule(kind string, factory func(cfg config.Rule) Rule) {
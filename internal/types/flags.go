@@ -2,6 +2,10 @@ package types
 
 type GlobalFlags struct {
 	Config      string
+	ProtoPath   []string
 	VendorDeps  bool
 	Concurrency int
+	Strict      bool
+	SourceRev   string
+	AllowCycles bool
 }
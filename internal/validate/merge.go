@@ -0,0 +1,73 @@
+package validate
+
+import (
+	"text/scanner"
+
+	"github.com/emicklei/proto"
+)
+
+// packageOptions are the file options that must agree across all files of a
+// package for code generators to place them together.
+var packageOptions = []string{
+	"go_package",
+	"java_package",
+	"java_multiple_files",
+	"csharp_namespace",
+	"objc_class_prefix",
+	"php_namespace",
+	"php_metadata_namespace",
+	"ruby_package",
+	"swift_prefix",
+}
+
+type optionValue struct {
+	file  *file
+	value string
+	pos   scanner.Position
+}
+
+// checkMergedOptions reports package-level file options whose values diverge
+// between files that were merged into one package. Every file is compared
+// with all earlier files of its package, and reported against the first one
+// it is merged with and disagrees with.
+func (c *checker) checkMergedOptions() {
+	seen := make(map[string]map[string][]optionValue)
+
+	for _, u := range c.files {
+		if u.proto == nil || u.OriginalPackage == "" {
+			continue
+		}
+
+		options := seen[u.pkg]
+		if options == nil {
+			options = make(map[string][]optionValue)
+			seen[u.pkg] = options
+		}
+
+		for _, element := range u.proto.Elements {
+			o, ok := element.(*proto.Option)
+			if !ok || !isPackageOption(o.Name) {
+				continue
+			}
+
+			current := optionValue{file: u, value: o.Constant.Source, pos: o.Position}
+			for _, earlier := range options[o.Name] {
+				if earlier.value != current.value && merged(earlier.file, u) {
+					c.report(u.Path, o.Position, false, "option %s = %q conflicts with %q at %s:%d (%s and %s are merged into %s)",
+						o.Name, current.value, earlier.value, earlier.file.Path, earlier.pos.Line, earlier.file.OriginalPackage, u.OriginalPackage, u.pkg)
+					break
+				}
+			}
+			options[o.Name] = append(options[o.Name], current)
+		}
+	}
+}
+
+func isPackageOption(name string) bool {
+	for _, option := range packageOptions {
+		if name == option {
+			return true
+		}
+	}
+	return false
+}
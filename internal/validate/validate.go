@@ -0,0 +1,325 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"github.com/emicklei/proto"
+	"github.com/jackchuka/proto-migrate/internal/descriptor"
+	"github.com/jackchuka/proto-migrate/internal/loader"
+	"github.com/jackchuka/proto-migrate/internal/resolve"
+)
+
+// File is a migrated proto file taking part in validation.
+type File struct {
+	// Path is the pre-migration location, used when reporting diagnostics.
+	Path string
+	// OutputPath is the location the migrated file will be written to.
+	OutputPath string
+	// Content is the migrated source.
+	Content string
+	// OriginalPackage is the package declared before migration. Files whose
+	// original packages differ but end up in the same package are merged.
+	OriginalPackage string
+}
+
+// Diagnostic describes a single problem found while compiling the migrated
+// files.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+	// Warning marks a diagnostic that does not stop the migration, such as
+	// an import that cannot be resolved yet.
+	Warning bool `json:"warning,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.File, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// Error is returned when the migrated schema does not compile.
+type Error struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("migrated schema does not compile:")
+	for _, d := range e.Diagnostics {
+		b.WriteString("\n  ")
+		b.WriteString(d.String())
+	}
+	return b.String()
+}
+
+// Validate compiles the migrated files together and reports every error,
+// such as undefined types, duplicate symbols or missing imports, along with
+// file options that conflict between packages merged by the migration.
+//
+// Files are named by their output path below the first of includePaths
+// containing it, which is how they import each other. deps maps import paths
+// to the content of read-only dependencies such as vendored protos; the
+// well-known types are always available. A file with an import that is not
+// available, directly or through the files it imports, cannot be compiled
+// and is reported with a warning instead.
+func Validate(files []File, deps map[string]string, includePaths []string) []Diagnostic {
+	c := &checker{
+		byName: make(map[string]*file),
+		stale:  make(map[string]*file),
+		deps:   deps,
+		state:  make(map[string]importState),
+	}
+	for _, f := range files {
+		output := &file{File: f, name: importName(f.OutputPath, includePaths)}
+		if definition, err := loader.Parse(f.Path, f.Content); err == nil {
+			output.proto = definition
+			proto.Walk(definition, proto.WithPackage(func(p *proto.Package) {
+				output.pkg = p.Name
+			}))
+		}
+		c.files = append(c.files, output)
+		c.byName[output.name] = output
+	}
+	sort.Slice(c.files, func(i, j int) bool { return c.files[i].Path < c.files[j].Path })
+	for _, f := range c.files {
+		if original := importName(f.Path, includePaths); original != f.name && c.byName[original] == nil {
+			c.stale[original] = f
+		}
+	}
+
+	var names []string
+	for _, f := range c.files {
+		if c.available(f.name) {
+			names = append(names, f.name)
+		}
+	}
+	c.compile(names)
+	c.checkMergedOptions()
+
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		a, b := c.diags[i], c.diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.diags
+}
+
+// file is a migrated file taking part in validation.
+type file struct {
+	File
+	// name is the import path the file is compiled as.
+	name  string
+	proto *proto.Proto
+	pkg   string
+}
+
+type importState int
+
+const (
+	unvisited importState = iota
+	visiting
+	available
+	unavailable
+)
+
+type checker struct {
+	files  []*file
+	byName map[string]*file
+	// stale maps the pre-migration import paths of moved files to them.
+	stale   map[string]*file
+	deps    map[string]string
+	depASTs map[string]*proto.Proto
+	state   map[string]importState
+	diags   []Diagnostic
+}
+
+func (c *checker) report(path string, pos scanner.Position, warning bool, format string, args ...any) {
+	c.diags = append(c.diags, Diagnostic{
+		File:    path,
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Message: fmt.Sprintf(format, args...),
+		Warning: warning,
+	})
+}
+
+// available reports whether the file named name and everything it imports
+// can be read, without import cycles. A migrated file that cannot be
+// compiled is reported against the import standing in the way.
+func (c *checker) available(name string) bool {
+	switch c.state[name] {
+	case available:
+		return true
+	case visiting, unavailable:
+		return false
+	}
+
+	imports, ok := c.imports(name)
+	if !ok {
+		c.state[name] = unavailable
+		return false
+	}
+	c.state[name] = visiting
+	ok = true
+	for _, i := range imports {
+		if c.available(i.Filename) {
+			continue
+		}
+		ok = false
+		if f := c.byName[name]; f != nil {
+			c.reportImport(f, i)
+		}
+	}
+	if ok {
+		c.state[name] = available
+	} else {
+		c.state[name] = unavailable
+	}
+	return ok
+}
+
+func (c *checker) reportImport(f *file, i *proto.Import) {
+	switch {
+	case c.state[i.Filename] == visiting:
+		c.report(f.Path, i.Position, true, "import %q is part of an import cycle, so this file is not checked", i.Filename)
+	case c.state[i.Filename] == unavailable && c.exists(i.Filename):
+		c.report(f.Path, i.Position, true, "import %q cannot be checked, so neither can this file", i.Filename)
+	case c.stale[i.Filename] != nil:
+		c.report(f.Path, i.Position, false, "import %q refers to the pre-migration location of %s", i.Filename, c.stale[i.Filename].Path)
+	default:
+		c.report(f.Path, i.Position, true, "import %q was not found, so this file is not checked", i.Filename)
+	}
+}
+
+func (c *checker) exists(name string) bool {
+	_, isDep := c.deps[name]
+	return c.byName[name] != nil || isDep || descriptor.IsStandardImport(name)
+}
+
+// imports returns the imports of the file named name, or false if there is
+// no such file. Files that do not parse are left to the compiler to report.
+func (c *checker) imports(name string) ([]*proto.Import, bool) {
+	var definition *proto.Proto
+	if f := c.byName[name]; f != nil {
+		definition = f.proto
+	} else if content, ok := c.deps[name]; ok {
+		if c.depASTs == nil {
+			c.depASTs = make(map[string]*proto.Proto)
+		}
+		if _, parsed := c.depASTs[name]; !parsed {
+			c.depASTs[name], _ = loader.Parse(name, content)
+		}
+		definition = c.depASTs[name]
+	} else if !descriptor.IsStandardImport(name) {
+		return nil, false
+	}
+
+	var imports []*proto.Import
+	if definition != nil {
+		proto.Walk(definition, proto.WithImport(func(i *proto.Import) {
+			imports = append(imports, i)
+		}))
+	}
+	return imports, true
+}
+
+// duplicateSymbol matches protoc's report of a symbol defined twice, with the
+// location of the first definition.
+var duplicateSymbol = regexp.MustCompile(`^(symbol "(.+)" already defined at )(.+):(\d+):(\d+)$`)
+
+// compile compiles the named files and reports every error against the
+// pre-migration path of the file it is in. Of a symbol defined twice, only
+// the outermost duplicate is reported, not each of its members.
+func (c *checker) compile(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	errs, err := descriptor.Check(context.Background(), names, func(name string) (string, bool) {
+		if f := c.byName[name]; f != nil {
+			return f.Content, true
+		}
+		content, ok := c.deps[name]
+		return content, ok
+	})
+	if err != nil {
+		c.report(c.byName[names[0]].Path, scanner.Position{}, false, "compiling: %v", err)
+	}
+
+	duplicates := make(map[string]bool)
+	for _, e := range errs {
+		if m := duplicateSymbol.FindStringSubmatch(e.Unwrap().Error()); m != nil {
+			duplicates[m[2]] = true
+		}
+	}
+	for _, e := range errs {
+		pos := scanner.Position{Filename: e.GetPosition().Filename, Line: e.GetPosition().Line, Column: e.GetPosition().Col}
+		message := e.Unwrap().Error()
+		if m := duplicateSymbol.FindStringSubmatch(message); m != nil {
+			if duplicates[resolve.ParentScope(m[2])] {
+				continue
+			}
+			message = c.duplicate(m, &pos)
+		}
+		path := pos.Filename
+		if f := c.byName[path]; f != nil {
+			path = f.Path
+		}
+		c.report(path, pos, false, "%s", message)
+	}
+}
+
+// duplicate rewrites a duplicate symbol report so that it is made against
+// the file sorting last, since files are compiled in no particular order,
+// and notes when the two files were merged into one package.
+func (c *checker) duplicate(m []string, pos *scanner.Position) string {
+	f, other := c.byName[pos.Filename], c.byName[m[3]]
+	if f == nil || other == nil {
+		return m[0]
+	}
+	message := m[0]
+	if other.Path > f.Path {
+		line, _ := strconv.Atoi(m[4])
+		column, _ := strconv.Atoi(m[5])
+		message = fmt.Sprintf("%s%s:%d:%d", m[1], f.name, pos.Line, pos.Column)
+		*pos = scanner.Position{Filename: other.name, Line: line, Column: column}
+		f, other = other, f
+	}
+	if merged(other, f) {
+		message += fmt.Sprintf(" (%s and %s are merged into %s)", other.OriginalPackage, f.OriginalPackage, f.pkg)
+	}
+	return message
+}
+
+// importName returns path relative to the first root containing it, or the
+// cleaned path if none does.
+func importName(path string, roots []string) string {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// merged reports whether a and b came from different packages that the
+// migration maps into the same one.
+func merged(a, b *file) bool {
+	return a.pkg == b.pkg && a.OriginalPackage != "" && b.OriginalPackage != "" && a.OriginalPackage != b.OriginalPackage
+}
@@ -0,0 +1,306 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	types := File{
+		Path:       "old/v1/types.proto",
+		OutputPath: "new/v1/types.proto",
+		Content: `syntax = "proto3";
+
+package new.v1;
+
+message Status {
+  string message = 1;
+}`,
+	}
+
+	tests := []struct {
+		name     string
+		files    []File
+		deps     map[string]string
+		wantErr  []string
+		wantWarn []string
+	}{
+		{
+			name: "valid schema",
+			files: []File{types, {
+				Path:       "old/v1/service.proto",
+				OutputPath: "new/v1/service.proto",
+				Content: `syntax = "proto3";
+
+package new.v1;
+
+import "google/protobuf/timestamp.proto";
+import "new/v1/types.proto";
+
+service ItemService {
+  rpc Get(Item) returns (Item);
+}
+
+message Item {
+  Status status = 1;
+  google.protobuf.Timestamp created_at = 2;
+  map<string, Status> by_name = 3;
+}`,
+			}},
+		},
+		{
+			name: "undefined type",
+			files: []File{{
+				Path:       "old/v1/item.proto",
+				OutputPath: "new/v1/item.proto",
+				Content: `syntax = "proto3";
+
+package new.v1;
+
+message Item {
+  old.v1.Status status = 1;
+}`,
+			}},
+			wantErr: []string{`old/v1/item.proto:6:3: field new.v1.Item.status: unknown type old.v1.Status`},
+		},
+		{
+			name: "missing import",
+			files: []File{types, {
+				Path:       "old/v1/item.proto",
+				OutputPath: "new/v1/item.proto",
+				Content: `syntax = "proto3";
+
+package new.v1;
+
+message Item {
+  Status status = 1;
+}`,
+			}},
+			wantErr: []string{`old/v1/item.proto:6:3: field new.v1.Item.status: unknown type Status`},
+		},
+		{
+			name: "import of pre-migration location",
+			files: []File{types, {
+				Path:       "old/v1/item.proto",
+				OutputPath: "new/v1/item.proto",
+				Content: `syntax = "proto3";
+
+package new.v1;
+
+import "old/v1/types.proto";
+
+message Item {
+  Status status = 1;
+}`,
+			}},
+			wantErr: []string{`import "old/v1/types.proto" refers to the pre-migration location of old/v1/types.proto`},
+		},
+		{
+			name: "duplicate symbol",
+			files: []File{types, {
+				Path:       "other/v1/types.proto",
+				OutputPath: "new/v1/more.proto",
+				Content: `syntax = "proto3";
+
+package new.v1;
+
+message Status {
+  string message = 1;
+}`,
+			}},
+			wantErr: []string{`other/v1/types.proto:5:9: symbol "new.v1.Status" already defined at new/v1/types.proto:5:9`},
+		},
+		{
+			name: "merged packages with colliding symbols and options",
+			files: []File{
+				{
+					Path:            "a/v1/item.proto",
+					OutputPath:      "merged/v1/a.proto",
+					OriginalPackage: "a.v1",
+					Content: `syntax = "proto3";
+
+package merged.v1;
+
+option go_package = "example.com/a/v1";
+
+message Item {
+  string id = 1;
+}`,
+				},
+				{
+					Path:            "b/v1/item.proto",
+					OutputPath:      "merged/v1/b.proto",
+					OriginalPackage: "b.v1",
+					Content: `syntax = "proto3";
+
+package merged.v1;
+
+option go_package = "example.com/b/v1";
+
+message Item {
+  string id = 1;
+}`,
+				},
+			},
+			wantErr: []string{
+				`b/v1/item.proto:5:1: option go_package = "example.com/b/v1" conflicts with "example.com/a/v1" at a/v1/item.proto:5 (a.v1 and b.v1 are merged into merged.v1)`,
+				`b/v1/item.proto:7:9: symbol "merged.v1.Item" already defined at merged/v1/a.proto:7:9 (a.v1 and b.v1 are merged into merged.v1)`,
+			},
+		},
+		{
+			name: "merged option conflicting with a later file",
+			files: []File{
+				{
+					Path:            "a/v1/one.proto",
+					OutputPath:      "merged/v1/one.proto",
+					OriginalPackage: "a.v1",
+					Content:         "syntax = \"proto3\";\npackage merged.v1;\noption go_package = \"example.com/a/v1\";\n",
+				},
+				{
+					Path:            "a/v1/two.proto",
+					OutputPath:      "merged/v1/two.proto",
+					OriginalPackage: "a.v1",
+					Content:         "syntax = \"proto3\";\npackage merged.v1;\noption go_package = \"example.com/a/v1/two\";\n",
+				},
+				{
+					Path:            "b/v1/item.proto",
+					OutputPath:      "merged/v1/b.proto",
+					OriginalPackage: "b.v1",
+					Content:         "syntax = \"proto3\";\npackage merged.v1;\noption go_package = \"example.com/a/v1\";\n",
+				},
+			},
+			wantErr: []string{
+				`b/v1/item.proto:3:1: option go_package = "example.com/a/v1" conflicts with "example.com/a/v1/two" at a/v1/two.proto:3 (a.v1 and b.v1 are merged into merged.v1)`,
+			},
+		},
+		{
+			name: "custom option from vendored dependency",
+			files: []File{{
+				Path:       "old/v1/item.proto",
+				OutputPath: "new/v1/item.proto",
+				Content: `syntax = "proto3";
+
+package new.v1;
+
+import "acme/options.proto";
+
+message Item {
+  string id = 1 [(acme.sensitive) = true];
+  string name = 2 [(acme.unknown) = true];
+}`,
+			}},
+			deps: map[string]string{
+				"acme/options.proto": `syntax = "proto3";
+
+package acme;
+
+import "google/protobuf/descriptor.proto";
+
+extend google.protobuf.FieldOptions {
+  bool sensitive = 50000;
+}`,
+			},
+			wantErr: []string{`old/v1/item.proto:9:20: field new.v1.Item.name: unknown extension acme.unknown`},
+		},
+		{
+			name: "unresolved external import is a warning",
+			files: []File{{
+				Path:       "old/v1/item.proto",
+				OutputPath: "new/v1/item.proto",
+				Content: `syntax = "proto3";
+
+package new.v1;
+
+import "thirdparty/money.proto";
+
+message Item {
+  thirdparty.Money price = 1;
+}`,
+			}},
+			wantWarn: []string{`old/v1/item.proto:5:1: import "thirdparty/money.proto" was not found`},
+		},
+		{
+			name: "file importing an unchecked file",
+			files: []File{
+				{
+					Path:       "old/v1/money.proto",
+					OutputPath: "new/v1/money.proto",
+					Content:    "syntax = \"proto3\";\npackage new.v1;\nimport \"thirdparty/currency.proto\";\nmessage Money {}\n",
+				},
+				{
+					Path:       "old/v1/item.proto",
+					OutputPath: "new/v1/item.proto",
+					Content:    "syntax = \"proto3\";\npackage new.v1;\nimport \"new/v1/money.proto\";\nmessage Item { Money price = 1; }\n",
+				},
+			},
+			wantWarn: []string{
+				`old/v1/item.proto:3:1: import "new/v1/money.proto" cannot be checked, so neither can this file`,
+				`old/v1/money.proto:3:1: import "thirdparty/currency.proto" was not found`,
+			},
+		},
+		{
+			name: "import cycle",
+			files: []File{
+				{
+					Path:       "old/v1/a.proto",
+					OutputPath: "new/v1/a.proto",
+					Content:    "syntax = \"proto3\";\npackage new.v1;\nimport \"new/v1/b.proto\";\nmessage A {}\n",
+				},
+				{
+					Path:       "old/v1/b.proto",
+					OutputPath: "new/v1/b.proto",
+					Content:    "syntax = \"proto3\";\npackage new.v1;\nimport \"new/v1/a.proto\";\nmessage B {}\n",
+				},
+			},
+			wantWarn: []string{
+				`old/v1/a.proto:3:1: import "new/v1/b.proto" cannot be checked`,
+				`old/v1/b.proto:3:1: import "new/v1/a.proto" is part of an import cycle`,
+			},
+		},
+		{
+			name: "import matching only a path suffix",
+			files: []File{types, {
+				Path:       "old/v1/item.proto",
+				OutputPath: "new/v1/item.proto",
+				Content: `syntax = "proto3";
+
+package new.v1;
+
+import "v1/types.proto";
+
+message Item {
+  Status status = 1;
+}`,
+			}},
+			wantWarn: []string{`import "v1/types.proto" was not found, so this file is not checked`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs, warnings []Diagnostic
+			for _, diag := range Validate(tt.files, tt.deps, nil) {
+				if diag.Warning {
+					warnings = append(warnings, diag)
+				} else {
+					errs = append(errs, diag)
+				}
+			}
+
+			for _, check := range []struct {
+				kind  string
+				diags []Diagnostic
+				want  []string
+			}{{"errors", errs, tt.wantErr}, {"warnings", warnings, tt.wantWarn}} {
+				if len(check.diags) != len(check.want) {
+					t.Fatalf("Validate() returned %d %s, want %d: %v", len(check.diags), check.kind, len(check.want), check.diags)
+				}
+				for i, want := range check.want {
+					if !strings.Contains(check.diags[i].String(), want) {
+						t.Errorf("%s %d = %q, want it to contain %q", check.kind, i, check.diags[i].String(), want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -17,6 +17,13 @@ type Options struct {
 	LogJSON       bool
 	Concurrency   int
 	DryRun        bool
+	Strict        bool
+	// AllowCycles writes the migrated schema even if it has import cycles.
+	AllowCycles bool
+	SourceRev   string
+	// DescriptorSetOut, if set, also writes the migrated schema as a
+	// FileDescriptorSet.
+	DescriptorSetOut string
 }
 
 func Run(ctx context.Context, opts Options) error {
@@ -27,8 +34,12 @@ func Run(ctx context.Context, opts Options) error {
 
 	flags := &types.GlobalFlags{
 		Config:      opts.Config,
+		ProtoPath:   opts.ProtoPath,
 		VendorDeps:  opts.VendorDeps,
 		Concurrency: opts.Concurrency,
+		Strict:      opts.Strict,
+		AllowCycles: opts.AllowCycles,
+		SourceRev:   opts.SourceRev,
 	}
 
 	eng := engine.New(cfg, flags)
@@ -46,5 +57,11 @@ func Run(ctx context.Context, opts Options) error {
 		return fmt.Errorf("applying: %w", err)
 	}
 
+	if opts.DescriptorSetOut != "" {
+		if err := eng.WriteDescriptorSet(ctx, plan, opts.DescriptorSetOut); err != nil {
+			return fmt.Errorf("writing descriptor set: %w", err)
+		}
+	}
+
 	return nil
 }